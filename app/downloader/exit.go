@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
+)
+
+// Exit codes, following the exit codes of wget.
+const (
+	exitOK       = 0
+	exitError    = 1 // Generic error
+	exitUsage    = 2 // Invalid flags or arguments
+	exitFileIO   = 3 // Save directory not found or file write error
+	exitNetwork  = 4 // Connection, DNS or timeout error
+	exitAuth     = 6 // Authentication required or rejected
+	exitProtocol = 7 // Redirect not allowed, or the downloaded file does not match the remote file
+	exitServer   = 8 // Error response from the server
+)
+
+// exitCodeUsage describes the exit codes in the usage of the command.
+const exitCodeUsage = `Exit codes:
+  0  all downloads completed
+  1  generic error
+  2  invalid flags or arguments
+  3  save directory not found or file write error
+  4  network failure
+  6  authentication failure
+  7  redirect not allowed, or size, checksum or remote file mismatch
+  8  error response from the server`
+
+// exitCodeOf returns the exit code of the error of a download.
+func exitCodeOf(err error) int {
+	var pathErr *os.PathError
+	if errors.Is(err, manager.ErrDirectoryNotFound) || errors.As(err, &pathErr) {
+		return exitFileIO
+	}
+
+	var statusErr *manager.HTTPStatusError
+	if errors.As(err, &statusErr) {
+		if statusErr.StatusCode == http.StatusUnauthorized || statusErr.StatusCode == http.StatusProxyAuthRequired {
+			return exitAuth
+		}
+
+		return exitServer
+	}
+
+	if errors.Is(err, manager.ErrRedirectNotAllowed) ||
+		errors.Is(err, manager.ErrRangeNotSupported) ||
+		errors.Is(err, manager.ErrSizeMismatch) ||
+		errors.Is(err, manager.ErrChecksumMismatch) ||
+		errors.Is(err, manager.ErrRemoteFileChanged) {
+		return exitProtocol
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return exitNetwork
+	}
+
+	return exitError
+}
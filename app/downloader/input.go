@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/util/file"
+)
+
+// inputFileUsage describes the format of the input file in the usage of the command.
+const inputFileUsage = `Input file:
+  Each line is a URL to download, optionally followed by tab separated mirror URLs.
+  The lines following a URL that start with a space or tab are options of that download, in the form key=value:
+    dir=DIRECTORY               directory to save the file in
+    out=FILE                    file name to save the file as
+    checksum=ALGORITHM=HEX      checksum to verify the file with, such as sha-256=2c26b4...
+                                the algorithm is one of md5, sha-1, sha-256 and sha-512
+    header=NAME: VALUE          custom header sent with every request, can be repeated
+    split=NUMBER                number of connections
+    max-download-limit=SPEED    maximum speed in bytes per second, such as 500K or 2M
+    user-agent=USER_AGENT       User-Agent header sent with every request
+    referer=URL                 Referer header sent with every request
+  Empty lines and lines starting with # are ignored.`
+
+// entry is a URL to download with the configurations of its download.
+// The configurations are applied after the configurations given by the flags.
+type entry struct {
+	url            string
+	configurations []manager.ConfigOption
+}
+
+// inputOptions are the options of a download in the input file.
+var inputOptions = map[string]func(value string) (manager.ConfigOption, error){
+	"dir": func(value string) (manager.ConfigOption, error) {
+		return manager.SaveDirectory(value), nil
+	},
+	"out": func(value string) (manager.ConfigOption, error) {
+		return manager.SaveFileName(value), nil
+	},
+	"checksum": func(value string) (manager.ConfigOption, error) {
+		algorithm, checksum, ok := cut(value, "=")
+		if !ok {
+			return nil, errors.New(`checksum must be in the form "algorithm=hex"`)
+		}
+
+		// The algorithm names of aria2 are accepted along with the names of the Digest header
+		hashAlgorithm := manager.HashAlgorithm(strings.ToLower(algorithm))
+		if hashAlgorithm == "sha-1" {
+			hashAlgorithm = manager.SHA1
+		}
+
+		return manager.Checksum(hashAlgorithm, checksum), nil
+	},
+	"header": func(value string) (manager.ConfigOption, error) {
+		var header headerFlag
+		if err := header.Set(value); err != nil {
+			return nil, err
+		}
+
+		name, value, _ := cut(value, ":")
+
+		return manager.Header(strings.TrimSpace(name), strings.TrimSpace(value)), nil
+	},
+	"split": func(value string) (manager.ConfigOption, error) {
+		nrOfConcurrentConnection, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, errors.New("split must be a number")
+		}
+
+		return manager.NrOfConcurrentDownload(nrOfConcurrentConnection), nil
+	},
+	"max-download-limit": func(value string) (manager.ConfigOption, error) {
+		speedLimit, err := file.ParseSize(value)
+		if err != nil {
+			return nil, err
+		}
+
+		return manager.SpeedLimit(speedLimit.Bytes()), nil
+	},
+	"user-agent": func(value string) (manager.ConfigOption, error) {
+		return manager.UserAgent(value), nil
+	},
+	"referer": func(value string) (manager.ConfigOption, error) {
+		return manager.Referer(value), nil
+	},
+}
+
+// readInputFile reads the URLs and the options of their downloads from an input file in the format of aria2.
+func readInputFile(r io.Reader) ([]entry, error) {
+	var entries []entry
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+
+		trimmedLine := strings.TrimSpace(line)
+		if trimmedLine == "" || strings.HasPrefix(trimmedLine, "#") {
+			continue
+		}
+
+		// A line starting with a space or tab is an option of the last URL
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(entries) == 0 {
+				return nil, fmt.Errorf("line %d: option given before any URL", lineNumber)
+			}
+
+			configuration, err := parseInputOption(trimmedLine)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+			}
+
+			last := &entries[len(entries)-1]
+			last.configurations = append(last.configurations, configuration)
+
+			continue
+		}
+
+		urls := strings.Fields(line)
+		e := entry{url: urls[0]}
+
+		if len(urls) > 1 {
+			e.configurations = append(e.configurations, manager.Mirrors(urls[1:]...))
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, scanner.Err()
+}
+
+// parseInputOption returns the configuration of an option of the input file in the form key=value.
+func parseInputOption(option string) (manager.ConfigOption, error) {
+	key, value, ok := cut(option, "=")
+	if !ok {
+		return nil, fmt.Errorf("option %q must be in the form key=value", option)
+	}
+
+	parse, ok := inputOptions[strings.TrimSpace(key)]
+	if !ok {
+		return nil, fmt.Errorf("unknown option %q", strings.TrimSpace(key))
+	}
+
+	return parse(strings.TrimSpace(value))
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
+)
+
+func TestReadInputFile(t *testing.T) {
+	directory := os.TempDir()
+
+	input := `# Datasets
+http://example.com/a.bin	http://mirror.example.com/a.bin
+  dir=` + directory + `
+  out=renamed.bin
+  checksum=sha-256=2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae
+	header=Cookie: id=1
+  split=4
+  max-download-limit=2M
+  user-agent=Datasets/1.0
+  referer=http://example.com/
+
+http://example.com/b.bin
+`
+
+	entries, err := readInputFile(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("Want 2 entries, got %d", len(entries))
+	}
+
+	if entries[1].url != "http://example.com/b.bin" || len(entries[1].configurations) != 0 {
+		t.Errorf("Want URL without options, got %q with %d options", entries[1].url, len(entries[1].configurations))
+	}
+
+	d, err := manager.NewDownload(append([]manager.ConfigOption{manager.DownloadURL(entries[0].url)}, entries[0].configurations...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		name string
+		get  interface{}
+		want interface{}
+	}{
+		{name: "Mirrors", get: d.Mirrors(), want: []string{"http://mirror.example.com/a.bin"}},
+		{name: "Directory", get: d.SaveDirectory(), want: directory},
+		{name: "File name", get: d.SaveFileName(), want: "renamed.bin"},
+		{name: "Checksum algorithm", get: d.ChecksumAlgorithm(), want: manager.SHA256},
+		{name: "Header", get: d.Header().Get("Cookie"), want: "id=1"},
+		{name: "Connections", get: d.MaxNrOfConcurrentConnection(), want: 4},
+		{name: "Speed limit", get: d.SpeedLimit(), want: int64(2 * 1024 * 1024)},
+		{name: "User agent", get: d.UserAgent(), want: "Datasets/1.0"},
+		{name: "Referer", get: d.Referer(), want: "http://example.com/"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if !reflect.DeepEqual(testCase.get, testCase.want) {
+				t.Errorf("Want %v, got %v", testCase.want, testCase.get)
+			}
+		})
+	}
+}
+
+func TestReadInputFileError(t *testing.T) {
+	var testCases = []struct {
+		name  string
+		input string
+	}{
+		{name: "Option before URL", input: " out=a.bin\nhttp://example.com/a.bin\n"},
+		{name: "Unknown option", input: "http://example.com/a.bin\n unknown=1\n"},
+		{name: "Option without value", input: "http://example.com/a.bin\n out\n"},
+		{name: "Invalid checksum", input: "http://example.com/a.bin\n checksum=2c26b4\n"},
+		{name: "Invalid header", input: "http://example.com/a.bin\n header=Cookie\n"},
+		{name: "Invalid split", input: "http://example.com/a.bin\n split=many\n"},
+		{name: "Invalid speed limit", input: "http://example.com/a.bin\n max-download-limit=fast\n"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if _, err := readInputFile(strings.NewReader(testCase.input)); err == nil {
+				t.Error("Want error, got nil")
+			}
+		})
+	}
+}
@@ -1,64 +1,428 @@
+// Command qdm downloads files over HTTP with concurrent connections.
+//
+// Usage:
+//
+//	qdm [flags] URL...
+//	qdm [flags] -i FILE [URL...]
+//
+// The URLs are given as arguments, or listed with the options of their downloads in an input file given by -i.
+// The downloads run at the same time, up to the number given by -j,
+// with the save directory, number of connections, speed limit and user agent of the user setting unless overridden by flags.
+// The user setting is loaded from the config file at $XDG_CONFIG_HOME/qdm/config,
+// and the flags are saved to it as the defaults of later runs with -save-config.
+// Build it with: go build -o qdm ./app/downloader
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
 
 	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
 	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/user/setting"
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/util/file"
 )
 
+// headerFlag collects the custom headers given by repeating a flag, in the form "Name: value".
+type headerFlag []string
+
+func (h *headerFlag) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlag) Set(header string) error {
+	if name, _, ok := cut(header, ":"); !ok || strings.TrimSpace(name) == "" {
+		return errors.New(`header must be in the form "Name: value"`)
+	}
+
+	*h = append(*h, header)
+
+	return nil
+}
+
+// options are the command line flags.
+type options struct {
+	saveDirectory            string
+	saveFileName             string
+	inputFile                string
+	nrOfConcurrentConnection int
+	maxActiveDownloads       int
+	speedLimit               string
+	userAgent                string
+	headers                  headerFlag
+	configPath               string
+	isSaveConfig             bool
+	isVerbose                bool
+	isQuiet                  bool
+
+	// Names of the flags given, as the flags of the user setting default to the config file
+	setFlags map[string]bool
+}
+
 func main() {
-	// Testing concurrent download
-	test()
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run downloads the URLs given by the command line arguments and the input file and returns the exit code.
+// The input file "-" is read from stdin.
+// The progress and the summary are written to stdout, and errors and log events to stderr.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	// The config file is not used if the config directory of the user is unknown
+	defaultConfigPath, _ := setting.DefaultConfigPath()
+
+	o := options{setFlags: make(map[string]bool)}
+
+	flags := flag.NewFlagSet("qdm", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: qdm [flags] URL...")
+		fmt.Fprintln(stderr, "       qdm [flags] -i FILE [URL...]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Flags:")
+		flags.PrintDefaults()
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, inputFileUsage)
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, exitCodeUsage)
+	}
+
+	flags.StringVar(&o.saveDirectory, "d", "", "`directory` to save the downloaded files in, the current directory unless set in the config file")
+	flags.StringVar(&o.saveFileName, "o", "", "`file` name to save a single download as, instead of the name given by the server")
+	flags.StringVar(&o.inputFile, "i", "", "input `file` listing URLs and the options of their downloads, or - to read from stdin")
+	flags.IntVar(&o.nrOfConcurrentConnection, "x", 0, fmt.Sprintf("`number` of connections per download, %d unless set in the config file", setting.DefaultNrOfConcurrentConnection))
+	flags.IntVar(&o.maxActiveDownloads, "j", manager.DefaultMaxActiveDownloads, "`number` of downloads running at the same time")
+	flags.StringVar(&o.speedLimit, "limit-rate", "", "maximum `speed` per download in bytes per second, such as 500K or 2M, unlimited unless set in the config file")
+	flags.StringVar(&o.userAgent, "user-agent", "", fmt.Sprintf("User-Agent header sent with every request, %q unless set in the config file", setting.DefaultUserAgent))
+	flags.Var(&o.headers, "H", "custom `header` sent with every request, such as \"Cookie: id=1\", can be repeated")
+	flags.StringVar(&o.configPath, "config", defaultConfigPath, "config `file` of the user setting")
+	flags.BoolVar(&o.isSaveConfig, "save-config", false, "save -d, -x, -limit-rate and -user-agent to the config file as the defaults of later runs")
+	flags.BoolVar(&o.isVerbose, "v", false, "log requests, responses and segments")
+	flags.BoolVar(&o.isQuiet, "q", false, "do not show the progress or log retries and failures, only the errors of the downloads")
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+
+		return exitUsage
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		o.setFlags[f.Name] = true
+	})
+
+	userSetting, code := o.loadSetting(stderr)
+	if userSetting == nil {
+		return code
+	}
+
+	var entries []entry
+	for _, url := range flags.Args() {
+		entries = append(entries, entry{url: url})
+	}
+
+	if o.inputFile != "" {
+		inputEntries, err := o.readEntries(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "qdm: %s: %v\n", o.inputFile, err)
+
+			// The input file cannot be read, as opposed to being invalid
+			var pathErr *os.PathError
+			if errors.As(err, &pathErr) {
+				return exitFileIO
+			}
+
+			return exitUsage
+		}
+
+		entries = append(entries, inputEntries...)
+	}
+
+	if err := o.apply(userSetting, entries); err != nil {
+		fmt.Fprintln(stderr, "qdm:", err)
+		flags.Usage()
+
+		return exitUsage
+	}
+
+	if o.isSaveConfig {
+		if err := userSetting.SaveConfig(o.configPath); err != nil {
+			fmt.Fprintln(stderr, "qdm:", err)
+			return exitFileIO
+		}
+
+		// Saving the config file does not need URLs
+		if len(entries) == 0 {
+			return exitOK
+		}
+	}
+
+	return download(entries, o, userSetting, stdout, stderr)
 }
 
-func test() {
-	// Test
-	const (
-		nrOfConcurrentDownload = 8
-		fileName               = "download.mp4"
-		directory              = `D:\Timothy/Desktop\`
-	)
+// loadSetting loads the user setting from the config file,
+// or returns a nil setting and the exit code if the config file is invalid or cannot be read.
+func (o *options) loadSetting(stderr io.Writer) (*setting.Setting, int) {
+	if o.configPath == "" {
+		userSetting, err := setting.NewSetting()
+		if err != nil {
+			fmt.Fprintln(stderr, "qdm:", err)
+			return nil, exitError
+		}
 
-	userSetting1, err := setting.NewSetting(
-		setting.NrOfConcurrentConnection(nrOfConcurrentDownload))
+		return userSetting, exitOK
+	}
+
+	userSetting, err := setting.LoadSetting(o.configPath)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(stderr, "qdm:", err)
+
+		var configErr *setting.ConfigError
+		if errors.As(err, &configErr) {
+			return nil, exitUsage
+		}
+
+		return nil, exitFileIO
 	}
 
-	// Sample download URLs:
-	// https://file-examples-com.github.io/uploads/2017/10/file-sample_150kB.pdf
-	// https://file-examples-com.github.io/uploads/2017/02/file-sample_100kB.doc
-	// https://file-examples-com.github.io/uploads/2017/04/file_example_MP4_1920_18MG.mp4
+	// Unknown keys are likely misspelled settings
+	if !o.isQuiet {
+		for _, key := range userSetting.UnknownConfigKeys() {
+			fmt.Fprintf(stderr, "qdm: %s: unknown setting %q is ignored\n", o.configPath, key)
+		}
+	}
+
+	return userSetting, exitOK
+}
 
-	// Test: Set URL in code
-	url := "https://file-examples-com.github.io/uploads/2017/04/file_example_MP4_1920_18MG.mp4"
-	fmt.Printf("URL is: %s\n\n", url)
+// readEntries reads the entries of the input file, or of stdin if the input file is "-".
+func (o *options) readEntries(stdin io.Reader) ([]entry, error) {
+	if o.inputFile == "-" {
+		return readInputFile(stdin)
+	}
 
-	// Initialize downloader new download
-	downloader, err := manager.NewDownload(
-		manager.DownloadURL(url),
-		manager.NrOfConcurrentDownload(userSetting1.NrOfConcurrentConnection()),
-		manager.SaveDirectory(directory),
-		manager.SaveFileName(fileName))
+	f, err := os.Open(o.inputFile)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	defer f.Close()
 
-	// Retrieve download details
-	if err = downloader.Initialize(); err != nil {
-		panic(err)
+	return readInputFile(f)
+}
+
+// apply validates the flags and the entries and updates the user setting with the flags given.
+func (o *options) apply(userSetting *setting.Setting, entries []entry) error {
+	if len(entries) == 0 && !o.isSaveConfig {
+		return errors.New("no URL given")
 	}
 
-	fmt.Println(downloader)
-	downloader.DebugHeader()
-	downloader.DebugFileSize()
+	if o.isSaveConfig && o.configPath == "" {
+		return errors.New("no config file to save to")
+	}
+
+	if o.saveFileName != "" && len(entries) > 1 {
+		return errors.New("a file name cannot be given for more than one URL")
+	}
+
+	if o.maxActiveDownloads < 1 {
+		return errors.New("number of downloads running at the same time must be at least 1")
+	}
+
+	if o.isVerbose && o.isQuiet {
+		return errors.New("-v and -q cannot be given together")
+	}
 
-	// Start the download
-	err = downloader.Start()
+	if o.setFlags["x"] {
+		if o.nrOfConcurrentConnection < 1 || o.nrOfConcurrentConnection > manager.MaxNrOfConcurrentConnectionAllowed {
+			return fmt.Errorf("number of connections must be between 1 and %d", manager.MaxNrOfConcurrentConnectionAllowed)
+		}
+
+		_ = userSetting.SetNrOfConcurrentConnection(o.nrOfConcurrentConnection)
+	}
+
+	if o.speedLimit != "" {
+		speedLimit, err := file.ParseSize(o.speedLimit)
+		if err != nil {
+			return err
+		}
+
+		if err = userSetting.SetSpeedLimit(speedLimit.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	if o.setFlags["d"] {
+		if err := userSetting.SetSaveDirectory(o.saveDirectory); err != nil {
+			return err
+		}
+	}
+
+	if o.setFlags["user-agent"] {
+		return userSetting.SetUserAgent(o.userAgent)
+	}
+
+	return nil
+}
+
+// download downloads the entries with a queue, writes a summary and returns the exit code of the first failed download in order.
+func download(entries []entry, o options, userSetting *setting.Setting, stdout, stderr io.Writer) int {
+	urls := make([]string, len(entries))
+	for i, e := range entries {
+		urls[i] = e.url
+	}
+
+	// Errors and log events are written above the progress
+	var display *progressDisplay
+	if !o.isQuiet {
+		display = newProgressDisplay(stdout, stderr, urls)
+		stderr = display
+	}
+
+	var logger manager.Logger
+	switch {
+	case o.isVerbose:
+		logger = manager.NewTextLogger(stderr, manager.DebugLevel)
+	case !o.isQuiet:
+		logger = manager.NewTextLogger(stderr, manager.WarnLevel)
+	}
+
+	if err := manager.SetGlobalSpeedLimit(userSetting.GlobalSpeedLimit()); err != nil {
+		fmt.Fprintln(stderr, "qdm:", err)
+		return exitError
+	}
+
+	// All downloads share the connections of a single transport
+	transport := userSetting.Transport()
+
+	// The error of each URL, in order
+	var mu sync.Mutex
+	errs := make([]error, len(urls))
+	indexes := make(map[*manager.Download]int)
+
+	queue, err := manager.NewQueue(
+		manager.MaxActiveDownloads(o.maxActiveDownloads),
+		manager.OnDownloadDone(func(d *manager.Download, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				fmt.Fprintf(stderr, "qdm: %s: %v\n", d.DownloadURL(), err)
+			}
+
+			if display != nil {
+				display.finish(indexes[d], err)
+			}
+
+			errs[indexes[d]] = err
+		}))
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(stderr, "qdm:", err)
+		return exitError
+	}
+
+	for i, url := range urls {
+		configurations := []manager.ConfigOption{
+			manager.DownloadURL(url),
+			manager.NrOfConcurrentDownload(userSetting.NrOfConcurrentConnection()),
+			manager.SpeedLimit(userSetting.SpeedLimit()),
+			manager.Transport(transport),
+			manager.UserAgent(userSetting.UserAgent()),
+			manager.SaveDirectory(userSetting.SaveDirectory()),
+		}
+
+		if o.saveFileName != "" {
+			configurations = append(configurations, manager.SaveFileName(o.saveFileName))
+		}
+
+		for _, header := range o.headers {
+			name, value, _ := cut(header, ":")
+			configurations = append(configurations, manager.Header(strings.TrimSpace(name), strings.TrimSpace(value)))
+		}
+
+		if logger != nil {
+			configurations = append(configurations, manager.Log(logger))
+		}
+
+		// The options of the input file override the flags
+		configurations = append(configurations, entries[i].configurations...)
+
+		if display != nil {
+			i := i
+			configurations = append(configurations,
+				manager.ProgressInterval(display.progressInterval()),
+				manager.OnProgress(func(p manager.Progress) {
+					display.update(i, p)
+				}))
+		}
+
+		d, err := manager.NewDownload(configurations...)
+		if err == nil {
+			mu.Lock()
+			indexes[d] = i
+			mu.Unlock()
+
+			if display != nil {
+				display.setDownload(i, d)
+			}
+
+			err = queue.Add(d, 0)
+		}
+
+		if err != nil {
+			fmt.Fprintf(stderr, "qdm: %s: %v\n", url, err)
+
+			mu.Lock()
+			errs[i] = err
+			mu.Unlock()
+		}
+	}
+
+	queue.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !o.isQuiet {
+		writeSummary(stdout, urls, errs)
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return exitCodeOf(err)
+		}
+	}
+
+	return exitOK
+}
+
+// writeSummary writes the number of downloads succeeded and failed, followed by the error of each failed download.
+func writeSummary(w io.Writer, urls []string, errs []error) {
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+
+	fmt.Fprintf(w, "Downloads: %d succeeded, %d failed\n", len(urls)-failed, failed)
+
+	for i, err := range errs {
+		if err != nil {
+			fmt.Fprintf(w, "  %s: %v\n", urls[i], err)
+		}
+	}
+}
+
+// cut slices s around the first instance of sep,
+// returning the text before and after sep and whether sep was found.
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
 	}
 
-	fmt.Println("Download complete:", downloader.IsDownloadComplete())
+	return s, "", false
 }
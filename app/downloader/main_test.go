@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/user/setting"
+)
+
+func TestRun(t *testing.T) {
+	content := bytes.Repeat([]byte("qdm"), 100*1024)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/download.bin":
+			if r.Header.Get("X-Token") != "secret" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			http.ServeContent(w, r, "download.bin", time.Time{}, bytes.NewReader(content))
+		case "/private.bin":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	directory, err := ioutil.TempDir("", "qdm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	var testCases = []struct {
+		name         string
+		args         []string
+		stdin        string
+		wantCode     int
+		wantFileName string
+		wantOutput   string
+	}{
+		{name: "Download", args: []string{"-H", "X-Token: secret", server.URL + "/download.bin"}, wantCode: exitOK, wantFileName: "download.bin"},
+		{name: "File name", args: []string{"-H", "X-Token: secret", "-o", "renamed.bin", "-x", "2", "-limit-rate", "10M", server.URL + "/download.bin"}, wantCode: exitOK, wantFileName: "renamed.bin"},
+		{name: "Not found", args: []string{server.URL + "/missing.bin"}, wantCode: exitServer},
+		{name: "Unauthorized", args: []string{server.URL + "/private.bin"}, wantCode: exitAuth},
+		{name: "First failed download in order", args: []string{server.URL + "/private.bin", server.URL + "/missing.bin"}, wantCode: exitAuth},
+		{
+			name:         "Input file",
+			args:         []string{"-i", "-"},
+			stdin:        "# Datasets\n" + server.URL + "/download.bin\t" + server.URL + "/download.bin\n header=X-Token: secret\n out=input.bin\n\n" + server.URL + "/missing.bin\n",
+			wantCode:     exitServer,
+			wantFileName: "input.bin",
+			wantOutput:   "Downloads: 1 succeeded, 1 failed\n  " + server.URL + "/missing.bin: ",
+		},
+		{
+			name:         "Input file with a single URL",
+			args:         []string{"-i", "-"},
+			stdin:        server.URL + "/download.bin\n header=X-Token: secret\n out=single.bin\n",
+			wantCode:     exitOK,
+			wantFileName: "single.bin",
+			wantOutput:   "Downloads: 1 succeeded, 0 failed\n",
+		},
+		{name: "Missing input file", args: []string{"-i", filepath.Join(directory, "missing.txt")}, wantCode: exitFileIO},
+		{name: "Invalid input file", args: []string{"-i", "-"}, stdin: server.URL + "\n unknown=1\n", wantCode: exitUsage},
+		{name: "Missing directory", args: []string{"-d", filepath.Join(directory, "missing"), server.URL + "/download.bin"}, wantCode: exitFileIO},
+		{name: "No URL", wantCode: exitUsage},
+		{name: "Unknown flag", args: []string{"-unknown", server.URL}, wantCode: exitUsage},
+		{name: "Invalid header", args: []string{"-H", "X-Token", server.URL}, wantCode: exitUsage},
+		{name: "Invalid speed limit", args: []string{"-limit-rate", "fast", server.URL}, wantCode: exitUsage},
+		{name: "File name for many URLs", args: []string{"-o", "a.bin", server.URL + "/a", server.URL + "/b"}, wantCode: exitUsage},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			args := append([]string{"-d", directory, "-config", filepath.Join(directory, "config")}, testCase.args...)
+
+			var stdout, stderr strings.Builder
+			if code := run(args, strings.NewReader(testCase.stdin), &stdout, &stderr); code != testCase.wantCode {
+				t.Fatalf("Want exit code %d, got %d: %s", testCase.wantCode, code, stderr.String())
+			}
+
+			if !strings.Contains(stdout.String(), testCase.wantOutput) {
+				t.Errorf("Want output containing %q, got %q", testCase.wantOutput, stdout.String())
+			}
+
+			if testCase.wantFileName == "" {
+				return
+			}
+
+			get, err := ioutil.ReadFile(filepath.Join(directory, testCase.wantFileName))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(content, get) {
+				t.Errorf("Want %d bytes of content, got %d bytes that differ", len(content), len(get))
+			}
+		})
+	}
+}
+
+func TestConfig(t *testing.T) {
+	directory, err := ioutil.TempDir("", "qdm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	configPath := filepath.Join(directory, "qdm", "config")
+	saveDirectory := filepath.Join(directory, "downloads")
+
+	if err = os.Mkdir(saveDirectory, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr strings.Builder
+
+	// The flags are saved without downloading
+	args := []string{"-config", configPath, "-save-config", "-d", saveDirectory, "-x", "3", "-limit-rate", "1M", "-user-agent", "Datasets/1.0"}
+	if code := run(args, nil, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Want exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+
+	userSetting, err := setting.LoadSetting(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if userSetting.SaveDirectory() != saveDirectory || userSetting.NrOfConcurrentConnection() != 3 ||
+		userSetting.SpeedLimit() != 1024*1024 || userSetting.UserAgent() != "Datasets/1.0" {
+		t.Errorf("Want the flags saved, got:\n%s", userSetting)
+	}
+
+	// Downloads are saved in the save directory of the config file without -d
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "download.bin", time.Time{}, strings.NewReader("qdm"))
+	}))
+	defer server.Close()
+
+	if code := run([]string{"-config", configPath, server.URL + "/download.bin"}, nil, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Want exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+
+	if _, err = os.Stat(filepath.Join(saveDirectory, "download.bin")); err != nil {
+		t.Errorf("Want download saved in the save directory of the config file, got %v", err)
+	}
+
+	// Unknown keys are kept when saving again
+	data := "{\n\t\"userAgent\": \"Datasets/2.0\",\n\t\"theme\": \"dark\"\n}\n"
+	if err = ioutil.WriteFile(configPath, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	stderr.Reset()
+	if code := run([]string{"-config", configPath, "-save-config", "-x", "4"}, nil, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Want exit code %d, got %d: %s", exitOK, code, stderr.String())
+	}
+
+	if want := `unknown setting "theme"`; !strings.Contains(stderr.String(), want) {
+		t.Errorf("Want %q, got %q", want, stderr.String())
+	}
+
+	userSetting, err = setting.LoadSetting(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if userSetting.NrOfConcurrentConnection() != 4 || userSetting.UserAgent() != "Datasets/2.0" {
+		t.Errorf("Want the flags saved over the config file, got:\n%s", userSetting)
+	}
+
+	if keys := userSetting.UnknownConfigKeys(); len(keys) != 1 || keys[0] != "theme" {
+		t.Errorf("Want unknown key theme kept, got %v", keys)
+	}
+
+	// Invalid settings are reported with their line
+	var testCases = []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "Syntax error", data: "{\n\t\"userAgent\": \"Datasets\",\n\t\"speedLimit\": 1,,\n}\n", want: configPath + ":3: invalid character"},
+		{name: "Invalid value", data: "{\n\t\"userAgent\": \"Datasets\",\n\t\"speedLimit\": -1\n}\n", want: configPath + ":3: speedLimit: invalid setting"},
+		{name: "Invalid type", data: "{\n\t\"connectTimeout\": 30\n}\n", want: configPath + ":2: connectTimeout: invalid setting"},
+		{name: "Not an object", data: "[]\n", want: configPath + ":1: config must be a JSON object"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := ioutil.WriteFile(configPath, []byte(testCase.data), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			var stderr strings.Builder
+			if code := run([]string{"-config", configPath, "http://example.com/a.bin"}, nil, &stdout, &stderr); code != exitUsage {
+				t.Fatalf("Want exit code %d, got %d: %s", exitUsage, code, stderr.String())
+			}
+
+			if !strings.Contains(stderr.String(), testCase.want) {
+				t.Errorf("Want %q, got %q", testCase.want, stderr.String())
+			}
+		})
+	}
+}
+
+func TestExitCodeOf(t *testing.T) {
+	var testCases = []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "Generic", err: errors.New("failed"), want: exitError},
+		{name: "Directory not found", err: manager.ErrDirectoryNotFound, want: exitFileIO},
+		{name: "File", err: &os.PathError{Op: "open", Path: "download.bin", Err: os.ErrPermission}, want: exitFileIO},
+		{name: "Unauthorized", err: &manager.HTTPStatusError{StatusCode: http.StatusUnauthorized}, want: exitAuth},
+		{name: "Server error", err: &manager.HTTPStatusError{StatusCode: http.StatusServiceUnavailable}, want: exitServer},
+		{name: "Checksum mismatch", err: manager.ErrChecksumMismatch, want: exitProtocol},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if get := exitCodeOf(testCase.err); get != testCase.want {
+				t.Errorf("Want %d, got %d", testCase.want, get)
+			}
+		})
+	}
+}
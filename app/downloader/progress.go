@@ -0,0 +1,349 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/util/file"
+)
+
+const (
+	// terminalProgressInterval is the interval at which the progress is redrawn in a terminal.
+	terminalProgressInterval = 200 * time.Millisecond
+
+	// plainProgressInterval is the interval at which a progress line is written when the output is not a terminal.
+	plainProgressInterval = 5 * time.Second
+
+	// defaultTerminalWidth is the width of the terminal if its size cannot be queried
+	// and the COLUMNS environment variable is not set.
+	defaultTerminalWidth = 80
+)
+
+// progressDisplay shows the progress of the downloads.
+// In a terminal, it redraws a row per download with its overall progress, speed and ETA,
+// followed by a bar per connection showing the progress and speed of the range it downloads.
+// Otherwise, it writes a line per download periodically and when the download finishes.
+type progressDisplay struct {
+	mu         sync.Mutex
+	writer     io.Writer
+	logWriter  io.Writer // Errors and log events written above the progress
+	isTerminal bool
+	width      int
+
+	downloads  []*displayedDownload
+	drawnLines int // Lines of progress drawn in the terminal, erased before redrawing
+}
+
+// displayedDownload is the latest progress of a download.
+type displayedDownload struct {
+	download      *manager.Download
+	name          string
+	progress      manager.Progress
+	isStarted     bool
+	isDone        bool
+	err           error
+	lastPlainTime time.Time
+}
+
+// newProgressDisplay returns a display of the progress of the URLs written to the writer,
+// and writes errors and log events to the log writer.
+func newProgressDisplay(writer, logWriter io.Writer, urls []string) *progressDisplay {
+	p := &progressDisplay{
+		writer:     writer,
+		logWriter:  logWriter,
+		isTerminal: isTerminal(writer),
+		width:      terminalWidth(writer),
+		downloads:  make([]*displayedDownload, len(urls)),
+	}
+
+	for i, url := range urls {
+		p.downloads[i] = &displayedDownload{name: url}
+	}
+
+	return p
+}
+
+// isTerminal returns a boolean indicating whether the writer is a terminal.
+func isTerminal(writer io.Writer) bool {
+	f, ok := writer.(*os.File)
+	if !ok {
+		return false
+	}
+
+	fileInfo, err := f.Stat()
+
+	return err == nil && fileInfo.Mode()&os.ModeCharDevice != 0
+}
+
+// terminalWidth returns the width of the terminal of the writer.
+// If the size of the terminal cannot be queried, it is taken from the COLUMNS environment variable.
+func terminalWidth(writer io.Writer) int {
+	if f, ok := writer.(*os.File); ok {
+		if width, ok := terminalSize(f); ok {
+			return width
+		}
+	}
+
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+
+	return defaultTerminalWidth
+}
+
+// progressInterval returns the interval at which the downloads report their progress.
+func (p *progressDisplay) progressInterval() time.Duration {
+	if p.isTerminal {
+		return terminalProgressInterval
+	}
+
+	return manager.DefaultProgressInterval
+}
+
+// setDownload sets the download of the URL at the index.
+func (p *progressDisplay) setDownload(i int, d *manager.Download) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.downloads[i].download = d
+}
+
+// update shows the progress of the download of the URL at the index.
+// It is called from the goroutine running the download.
+func (p *progressDisplay) update(i int, progress manager.Progress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	dd := p.downloads[i]
+	dd.progress = progress
+	dd.isStarted = true
+
+	// The download is named after its file once it is initialized
+	if dd.download != nil && dd.download.SaveFullPath() != "" {
+		dd.name = filepath.Base(dd.download.SaveFullPath())
+	}
+
+	if p.isTerminal {
+		p.redraw()
+		return
+	}
+
+	if now := time.Now(); now.Sub(dd.lastPlainTime) >= plainProgressInterval {
+		dd.lastPlainTime = now
+		_, _ = io.WriteString(p.writer, p.plainLine(dd)+"\n")
+	}
+}
+
+// finish shows the download of the URL at the index finished with the error, if any.
+func (p *progressDisplay) finish(i int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	dd := p.downloads[i]
+	dd.isDone = true
+	dd.err = err
+
+	if p.isTerminal {
+		p.redraw()
+		return
+	}
+
+	_, _ = io.WriteString(p.writer, p.plainLine(dd)+"\n")
+}
+
+// Write writes errors and log events to the log writer above the progress drawn in the terminal.
+func (p *progressDisplay) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.erase()
+	n, err := p.logWriter.Write(b)
+	p.draw()
+
+	return n, err
+}
+
+// redraw replaces the progress drawn in the terminal with the latest progress.
+func (p *progressDisplay) redraw() {
+	p.erase()
+	p.draw()
+}
+
+// erase moves the cursor to the first line of progress drawn in the terminal and clears the lines below it.
+func (p *progressDisplay) erase() {
+	if !p.isTerminal || p.drawnLines == 0 {
+		return
+	}
+
+	_, _ = fmt.Fprintf(p.writer, "\x1b[%dA\x1b[J", p.drawnLines)
+	p.drawnLines = 0
+}
+
+// draw draws the progress of the started downloads in the terminal.
+func (p *progressDisplay) draw() {
+	if !p.isTerminal {
+		return
+	}
+
+	var lines []string
+	for _, dd := range p.downloads {
+		if dd.isStarted || dd.isDone {
+			lines = append(lines, p.terminalLines(dd)...)
+		}
+	}
+
+	for _, line := range lines {
+		_, _ = io.WriteString(p.writer, line+"\n")
+	}
+
+	p.drawnLines = len(lines)
+}
+
+// terminalLines returns the row of a download followed by a bar per connection while it is running.
+func (p *progressDisplay) terminalLines(dd *displayedDownload) []string {
+	lines := []string{p.truncate(p.plainLine(dd))}
+	if dd.isDone {
+		return lines
+	}
+
+	// Each running segment is downloaded by a connection
+	connection := 0
+	for _, segment := range dd.progress.Segments {
+		if segment.RangeEnd >= 0 && segment.BytesCompleted >= segment.RangeEnd-segment.RangeStart+1 {
+			continue
+		}
+
+		connection++
+		lines = append(lines, p.segmentLine(connection, segment))
+	}
+
+	return lines
+}
+
+// segmentLine returns the bar of the segment downloaded by a connection, followed by its range and speed.
+func (p *progressDisplay) segmentLine(connection int, segment manager.SegmentProgress) string {
+	rangeEnd := "?"
+	if segment.RangeEnd >= 0 {
+		rangeEnd = strconv.FormatInt(segment.RangeEnd, 10)
+	}
+
+	suffix := fmt.Sprintf(" %s-%s %s/s",
+		strconv.FormatInt(segment.RangeStart, 10), rangeEnd, formatSize(int64(segment.Speed)))
+	prefix := fmt.Sprintf("  #%-2d ", connection)
+
+	// The bar takes the width left by the prefix and suffix
+	barWidth := p.width - len(prefix) - len(suffix) - 2
+	if barWidth < 10 {
+		barWidth = 10
+	}
+
+	filled := 0
+	if segment.RangeEnd >= 0 {
+		filled = int(float64(segment.BytesCompleted) / float64(segment.RangeEnd-segment.RangeStart+1) * float64(barWidth))
+	}
+
+	if filled > barWidth {
+		filled = barWidth
+	}
+
+	return p.truncate(prefix + "[" + strings.Repeat("#", filled) + strings.Repeat("-", barWidth-filled) + "]" + suffix)
+}
+
+// plainLine returns a line with the overall progress, speed and ETA of a download, or its result once done.
+func (p *progressDisplay) plainLine(dd *displayedDownload) string {
+	name := dd.name
+	progress := dd.progress
+
+	switch {
+	case dd.err != nil:
+		return fmt.Sprintf("%s: failed: %v", name, dd.err)
+	case dd.isDone:
+		return fmt.Sprintf("%s: done, %s in %s", name, formatSize(progress.BytesCompleted), formatDuration(progress.Elapsed))
+	case progress.FileSize < 0:
+		return fmt.Sprintf("%s: %s at %s/s", name, formatSize(progress.BytesCompleted), formatSize(int64(progress.Speed)))
+	}
+
+	percent := 100.0
+	if progress.FileSize > 0 {
+		percent = float64(progress.BytesCompleted) / float64(progress.FileSize) * 100
+	}
+
+	return fmt.Sprintf("%s: %5.1f%% %s of %s at %s/s, ETA %s",
+		name, percent, formatSize(progress.BytesCompleted), formatSize(progress.FileSize),
+		formatSize(int64(progress.Speed)), formatDuration(progress.ETA))
+}
+
+// truncate cuts a line to the width of the terminal so it does not wrap.
+// The line is cut between characters by their display width, so file names in any script are not garbled.
+func (p *progressDisplay) truncate(line string) string {
+	width := 0
+	for i, r := range line {
+		width += runeWidth(r)
+		if width > p.width {
+			return line[:i]
+		}
+	}
+
+	return line
+}
+
+// runeWidth returns the number of terminal columns a character takes:
+// 0 for combining marks and control characters, 2 for wide East Asian characters and emoji, otherwise 1.
+func runeWidth(r rune) int {
+	switch {
+	case unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) || unicode.IsControl(r) || r == '\u200b':
+		return 0
+	case r >= 0x1100 && r <= 0x115f, // Hangul Jamo
+		r >= 0x2e80 && r <= 0x303e, // CJK radicals and punctuation
+		r >= 0x3041 && r <= 0x33ff, // Kana and CJK symbols
+		r >= 0x3400 && r <= 0x4dbf, // CJK unified ideographs extension A
+		r >= 0x4e00 && r <= 0x9fff, // CJK unified ideographs
+		r >= 0xa000 && r <= 0xa4cf, // Yi
+		r >= 0xac00 && r <= 0xd7a3, // Hangul syllables
+		r >= 0xf900 && r <= 0xfaff, // CJK compatibility ideographs
+		r >= 0xfe30 && r <= 0xfe4f, // CJK compatibility forms
+		r >= 0xff00 && r <= 0xff60, // Fullwidth forms
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x1f300 && r <= 0x1f64f, // Emoji
+		r >= 0x1f900 && r <= 0x1f9ff,
+		r >= 0x20000 && r <= 0x3fffd: // CJK unified ideographs extensions
+		return 2
+	}
+
+	return 1
+}
+
+// formatSize returns a number of bytes with a binary unit, such as "1.5 MiB".
+func formatSize(bytes int64) string {
+	size := file.Size(bytes)
+
+	switch {
+	case size.TB() >= 1:
+		return fmt.Sprintf("%.1f TiB", size.TB())
+	case size.GB() >= 1:
+		return fmt.Sprintf("%.1f GiB", size.GB())
+	case size.MB() >= 1:
+		return fmt.Sprintf("%.1f MiB", size.MB())
+	case size.KB() >= 1:
+		return fmt.Sprintf("%.1f KiB", size.KB())
+	}
+
+	return strconv.FormatInt(bytes, 10) + " B"
+}
+
+// formatDuration returns a duration rounded to seconds, or "--" if it is unknown.
+func formatDuration(d time.Duration) string {
+	if d < 0 {
+		return "--"
+	}
+
+	return d.Round(time.Second).String()
+}
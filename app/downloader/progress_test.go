@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
+)
+
+func TestProgressDisplay(t *testing.T) {
+	progress := manager.Progress{
+		BytesCompleted: 3 * 1024 * 1024,
+		FileSize:       4 * 1024 * 1024,
+		Speed:          512 * 1024,
+		ETA:            2 * time.Second,
+		Segments: []manager.SegmentProgress{
+			{RangeStart: 0, RangeEnd: 2*1024*1024 - 1, BytesCompleted: 2 * 1024 * 1024},
+			{RangeStart: 2 * 1024 * 1024, RangeEnd: 4*1024*1024 - 1, BytesCompleted: 1024 * 1024, Speed: 512 * 1024},
+		},
+	}
+
+	t.Run("Terminal", func(t *testing.T) {
+		var output strings.Builder
+
+		display := newProgressDisplay(&output, &output, []string{"http://example.com/a.bin"})
+		display.isTerminal = true
+		display.width = 60
+
+		display.update(0, progress)
+
+		lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+
+		// The row of the download is followed by a bar for the only running segment
+		want := []string{
+			"http://example.com/a.bin:  75.0% 3.0 MiB of 4.0 MiB at 512.0 KiB/s, ETA 2s",
+			"  #1  [############------------] 2097152-4194303 512.0 KiB/s",
+		}
+
+		if len(lines) != len(want) {
+			t.Fatalf("Want %d lines, got %d: %q", len(want), len(lines), output.String())
+		}
+
+		if lines[0] != want[0][:display.width] {
+			t.Errorf("Want %q, got %q", want[0][:display.width], lines[0])
+		}
+
+		if lines[1] != want[1] {
+			t.Errorf("Want %q, got %q", want[1], lines[1])
+		}
+
+		// Log events erase the progress and are written above it
+		output.Reset()
+		_, _ = display.Write([]byte("log\n"))
+
+		if !strings.HasPrefix(output.String(), "\x1b[2A\x1b[Jlog\n") {
+			t.Errorf("Want progress erased before the log event, got %q", output.String())
+		}
+	})
+
+	t.Run("Plain", func(t *testing.T) {
+		var output strings.Builder
+
+		display := newProgressDisplay(&output, &output, []string{"http://example.com/a.bin", "http://example.com/b.bin"})
+
+		// A line is written periodically, and when the download finishes
+		display.update(0, progress)
+		display.update(0, progress)
+		display.finish(0, nil)
+		display.finish(1, errors.New("not found"))
+
+		want := "http://example.com/a.bin:  75.0% 3.0 MiB of 4.0 MiB at 512.0 KiB/s, ETA 2s\n" +
+			"http://example.com/a.bin: done, 3.0 MiB in 0s\n" +
+			"http://example.com/b.bin: failed: not found\n"
+
+		if output.String() != want {
+			t.Errorf("Want %q, got %q", want, output.String())
+		}
+	})
+}
+
+func TestTruncate(t *testing.T) {
+	var testCases = []struct {
+		name  string
+		line  string
+		width int
+		want  string
+	}{
+		{name: "Short", line: "a.bin", width: 10, want: "a.bin"},
+		{name: "ASCII", line: "download.bin: done", width: 10, want: "download.b"},
+		{name: "Accented", line: "données_été.bin: done", width: 10, want: "données_ét"},
+		{name: "Combining mark", line: "été.bin: done", width: 3, want: "été"},
+		{name: "Wide characters", line: "数据集文件.bin: done", width: 10, want: "数据集文件"},
+		{name: "Wide character cut", line: "数据集文件.bin: done", width: 9, want: "数据集文"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			display := newProgressDisplay(ioutil.Discard, ioutil.Discard, nil)
+			display.width = testCase.width
+
+			get := display.truncate(testCase.line)
+			if get != testCase.want {
+				t.Errorf("Want %q, got %q", testCase.want, get)
+			}
+
+			if !utf8.ValidString(get) {
+				t.Errorf("Want valid UTF-8, got %q", get)
+			}
+		})
+	}
+}
+
+func TestTerminalWidth(t *testing.T) {
+	columns, isSet := os.LookupEnv("COLUMNS")
+	defer func() {
+		if isSet {
+			_ = os.Setenv("COLUMNS", columns)
+		} else {
+			_ = os.Unsetenv("COLUMNS")
+		}
+	}()
+
+	// The size of a pipe cannot be queried, so the width falls back to COLUMNS and then the default width
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	defer writer.Close()
+
+	var testCases = []struct {
+		name    string
+		columns string
+		want    int
+	}{
+		{name: "COLUMNS", columns: "120", want: 120},
+		{name: "Invalid COLUMNS", columns: "wide", want: defaultTerminalWidth},
+		{name: "No COLUMNS", want: defaultTerminalWidth},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := os.Setenv("COLUMNS", testCase.columns); err != nil {
+				t.Fatal(err)
+			}
+
+			if width := terminalWidth(writer); width != testCase.want {
+				t.Errorf("Want %v, got %v", testCase.want, width)
+			}
+
+			if width := terminalWidth(&strings.Builder{}); width != testCase.want {
+				t.Errorf("Want %v, got %v", testCase.want, width)
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package main
+
+import "os"
+
+// terminalSize returns false as querying the size of the terminal is not supported on this system.
+func terminalSize(f *os.File) (int, bool) {
+	return 0, false
+}
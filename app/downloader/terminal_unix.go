@@ -0,0 +1,24 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// terminalSize returns the number of columns of the terminal of the file,
+// queried with the TIOCGWINSZ ioctl, or false if the file is not a terminal.
+func terminalSize(f *os.File) (int, bool) {
+	var size struct {
+		rows, columns, xPixels, yPixels uint16
+	}
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&size))); errno != 0 {
+		return 0, false
+	}
+
+	return int(size.columns), size.columns > 0
+}
@@ -0,0 +1,473 @@
+package manager
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// authentication holds the credentials of a download, shared by the download and its segments.
+// Basic credentials are only sent to a host once it challenges them with basic or digest authentication.
+// Without credentials set, they are looked up by host in the .netrc file.
+type authentication struct {
+	username    string
+	password    string
+	bearerToken string
+	netrcFile   string
+
+	mu               sync.Mutex
+	isNetrcRead      bool
+	netrcData        string
+	digestChallenges map[string]*digestChallenge // Digest challenge received by host
+	basicChallenges  map[string]bool             // Hosts that challenged basic authentication
+}
+
+// digestChallenge is a challenge of the digest authentication scheme sent by a server.
+//
+// https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/WWW-Authenticate
+type digestChallenge struct {
+	realm       string
+	nonce       string
+	opaque      string
+	algorithm   string
+	qop         string
+	nonceCount  int
+	isSessioned bool
+}
+
+// Username returns the user name sent to the download server, set by SetBasicAuth.
+func (d *Download) Username() string {
+	return d.auth.username
+}
+
+// SetBasicAuth sets the user name and password sent to the download server and its mirrors
+// and returns a non nil error if failed to set.
+// They are sent with basic or digest authentication once the server asks for it.
+func (d *Download) SetBasicAuth(username, password string) error {
+	if username == "" {
+		return errors.New("user name is empty")
+	}
+
+	d.auth.username = username
+	d.auth.password = password
+
+	return nil
+}
+
+// SetBearerToken sets the bearer token sent to the download server and its mirrors
+// and returns a non nil error if failed to set.
+func (d *Download) SetBearerToken(bearerToken string) error {
+	if bearerToken == "" {
+		return errors.New("bearer token is empty")
+	}
+
+	d.auth.bearerToken = bearerToken
+
+	return nil
+}
+
+// NetrcFile returns the path of the .netrc file credentials are looked up in
+// when no credentials are set.
+func (d *Download) NetrcFile() string {
+	if d.auth.netrcFile != "" {
+		return d.auth.netrcFile
+	}
+
+	if netrcFile := os.Getenv("NETRC"); netrcFile != "" {
+		return netrcFile
+	}
+
+	homeDirectory, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(homeDirectory, ".netrc")
+}
+
+// SetNetrcFile sets the path of the .netrc file credentials are looked up in
+// when no credentials are set, instead of the NETRC environment variable or ~/.netrc.
+func (d *Download) SetNetrcFile(netrcFile string) error {
+	if netrcFile == "" {
+		return errors.New(".netrc file path is empty")
+	}
+
+	d.auth.netrcFile = netrcFile
+
+	return nil
+}
+
+// authorize adds the credentials for the host of the request to the request.
+// The bearer token is sent straight away, while the user name and password
+// are only sent once the host challenged them.
+func (d *Download) authorize(req *http.Request) {
+	a := d.auth
+
+	if a.bearerToken != "" && d.isCredentialHost(req.URL) {
+		req.Header.Set("Authorization", "Bearer "+a.bearerToken)
+		return
+	}
+
+	a.mu.Lock()
+	challenge := a.digestChallenges[req.URL.Host]
+	isBasicChallenged := a.basicChallenges[req.URL.Host]
+	a.mu.Unlock()
+
+	if challenge == nil && !isBasicChallenged {
+		return
+	}
+
+	username, password, ok := d.credentials(req.URL)
+	if !ok {
+		return
+	}
+
+	if challenge != nil {
+		req.Header.Set("Authorization", a.digestAuthorization(challenge, username, password, req))
+		return
+	}
+
+	req.SetBasicAuth(username, password)
+}
+
+// credentials returns the user name and password set, or found for the host of the URL in the .netrc file.
+func (d *Download) credentials(u *url.URL) (username, password string, ok bool) {
+	a := d.auth
+	if a.username != "" && d.isCredentialHost(u) {
+		return a.username, a.password, true
+	}
+
+	a.mu.Lock()
+	if !a.isNetrcRead {
+		// A missing .netrc file has no credentials
+		data, _ := ioutil.ReadFile(d.NetrcFile())
+		a.netrcData = string(data)
+		a.isNetrcRead = true
+	}
+	netrcData := a.netrcData
+	a.mu.Unlock()
+
+	return parseNetrc(netrcData, u.Hostname())
+}
+
+// isCredentialHost returns a boolean indicating whether the credentials set are sent to the host of the URL.
+// They are only sent to the host of the download URL and of the mirrors set,
+// as segments request the URL redirected to, which is often a CDN or a presigned URL of another host.
+func (d *Download) isCredentialHost(u *url.URL) bool {
+	root := d
+	if d.parent != nil {
+		root = d.parent
+	}
+
+	if root.downloadURL != nil && root.downloadURL.Host == u.Host {
+		return true
+	}
+
+	for _, mirrorURL := range root.mirrorURLs {
+		if mirrorURL.Host == u.Host {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isChallenged records the digest or basic challenge of a 401 Unauthorized response
+// and returns a boolean indicating whether the request should be sent again to answer it.
+// The challenge is recorded for the host that sent it, which is the host redirected to for a redirected request,
+// and is answered by authorize for every later request to that host, including the redirects to it.
+// A challenge is answered once, unless the server marks the digest nonce answered before as stale.
+func (d *Download) isChallenged(response *http.Response) bool {
+	host := response.Request.URL.Host
+
+	if response.StatusCode != http.StatusUnauthorized ||
+		d.auth.bearerToken != "" && d.isCredentialHost(response.Request.URL) {
+		return false
+	}
+
+	if _, _, ok := d.credentials(response.Request.URL); !ok {
+		return false
+	}
+
+	a := d.auth
+	isBasic := false
+
+	// Digest authentication is preferred as it does not send the password
+	for _, value := range response.Header.Values("WWW-Authenticate") {
+		scheme, params, _ := cut(strings.TrimSpace(value), " ")
+		if strings.EqualFold(scheme, "Basic") {
+			isBasic = true
+		}
+
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+
+		challenge, isStale := parseDigestChallenge(params)
+		if challenge == nil {
+			return false
+		}
+
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		if a.digestChallenges[host] != nil && !isStale {
+			return false
+		}
+
+		if a.digestChallenges == nil {
+			a.digestChallenges = make(map[string]*digestChallenge)
+		}
+
+		a.digestChallenges[host] = challenge
+
+		return true
+	}
+
+	if !isBasic {
+		return false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.basicChallenges[host] {
+		return false
+	}
+
+	if a.basicChallenges == nil {
+		a.basicChallenges = make(map[string]bool)
+	}
+
+	a.basicChallenges[host] = true
+
+	return true
+}
+
+// parseDigestChallenge parses the parameters of a digest challenge
+// and returns the challenge and whether the nonce answered before is stale,
+// or a nil challenge if its algorithm or quality of protection is not supported.
+func parseDigestChallenge(params string) (*digestChallenge, bool) {
+	values := parseAuthParams(params)
+
+	challenge := &digestChallenge{
+		realm:     values["realm"],
+		nonce:     values["nonce"],
+		opaque:    values["opaque"],
+		algorithm: values["algorithm"],
+	}
+
+	algorithm := strings.ToUpper(challenge.algorithm)
+	if strings.HasSuffix(algorithm, "-SESS") {
+		challenge.isSessioned = true
+		algorithm = strings.TrimSuffix(algorithm, "-SESS")
+	}
+
+	if algorithm != "" && algorithm != "MD5" && algorithm != "SHA-256" {
+		return nil, false
+	}
+
+	// Only authentication is supported as the quality of protection, not integrity of the request body
+	if qop, ok := values["qop"]; ok {
+		for _, option := range strings.Split(qop, ",") {
+			if strings.TrimSpace(option) == "auth" {
+				challenge.qop = "auth"
+			}
+		}
+
+		if challenge.qop == "" {
+			return nil, false
+		}
+	}
+
+	return challenge, strings.EqualFold(values["stale"], "true")
+}
+
+// digestAuthorization returns the value of the Authorization header answering a digest challenge for the request.
+//
+// https://tools.ietf.org/html/rfc7616
+func (a *authentication) digestAuthorization(challenge *digestChallenge, username, password string, req *http.Request) string {
+	var newHash func() hash.Hash = md5.New
+	if strings.HasPrefix(strings.ToUpper(challenge.algorithm), "SHA-256") {
+		newHash = sha256.New
+	}
+
+	h := func(s string) string {
+		hh := newHash()
+		_, _ = hh.Write([]byte(s))
+
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+
+	cnonceBytes := make([]byte, 16)
+	_, _ = rand.Read(cnonceBytes)
+	cnonce := hex.EncodeToString(cnonceBytes)
+
+	// Every request sent with the same nonce is counted
+	a.mu.Lock()
+	challenge.nonceCount++
+	nonceCount := strconv.FormatInt(int64(challenge.nonceCount), 16)
+	a.mu.Unlock()
+
+	nonceCount = strings.Repeat("0", 8-len(nonceCount)) + nonceCount
+	uri := req.URL.RequestURI()
+
+	ha1 := h(username + ":" + challenge.realm + ":" + password)
+	if challenge.isSessioned {
+		ha1 = h(ha1 + ":" + challenge.nonce + ":" + cnonce)
+	}
+
+	ha2 := h(req.Method + ":" + uri)
+
+	response := h(ha1 + ":" + challenge.nonce + ":" + ha2)
+	if challenge.qop != "" {
+		response = h(ha1 + ":" + challenge.nonce + ":" + nonceCount + ":" + cnonce + ":" + challenge.qop + ":" + ha2)
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(`Digest username="` + username + `"`)
+	sb.WriteString(`, realm="` + challenge.realm + `"`)
+	sb.WriteString(`, nonce="` + challenge.nonce + `"`)
+	sb.WriteString(`, uri="` + uri + `"`)
+	sb.WriteString(`, response="` + response + `"`)
+
+	if challenge.algorithm != "" {
+		sb.WriteString(", algorithm=" + challenge.algorithm)
+	}
+
+	if challenge.opaque != "" {
+		sb.WriteString(`, opaque="` + challenge.opaque + `"`)
+	}
+
+	if challenge.qop != "" {
+		sb.WriteString(", qop=" + challenge.qop + ", nc=" + nonceCount + `, cnonce="` + cnonce + `"`)
+	}
+
+	return sb.String()
+}
+
+// parseAuthParams parses comma separated authentication parameters, such as realm="x", nonce="y",
+// with values that are tokens or quoted strings, and returns the values by lower case name.
+func parseAuthParams(params string) map[string]string {
+	values := make(map[string]string)
+
+	for {
+		params = strings.TrimLeft(params, " ,")
+		if params == "" {
+			return values
+		}
+
+		name, rest, ok := cut(params, "=")
+		if !ok {
+			return values
+		}
+
+		name = strings.ToLower(strings.TrimSpace(name))
+		rest = strings.TrimLeft(rest, " ")
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			// Quoted string with backslash escapes
+			var sb strings.Builder
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+
+				sb.WriteByte(rest[i])
+			}
+
+			value = sb.String()
+			if i < len(rest) {
+				i++
+			}
+
+			params = rest[i:]
+		} else {
+			value, params, _ = cut(rest, ",")
+			value = strings.TrimSpace(value)
+		}
+
+		values[name] = value
+	}
+}
+
+// parseNetrc returns the login and password of the machine matching the host in the content of a .netrc file,
+// or of the default entry if no machine matches.
+//
+// https://www.gnu.org/software/inetutils/manual/html_node/The-_002enetrc-file.html
+func parseNetrc(data, host string) (login, password string, ok bool) {
+	var defaultLogin, defaultPassword string
+	var isDefault, isMatching, isDefaultFound bool
+
+	lines := strings.Split(data, "\n")
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Fields(lines[i])
+
+		for j := 0; j < len(fields); j++ {
+			var value string
+			if j+1 < len(fields) {
+				value = fields[j+1]
+			}
+
+			switch fields[j] {
+			case "machine":
+				if isMatching {
+					return login, password, true
+				}
+
+				isMatching = value == host
+				isDefault = false
+				j++
+			case "default":
+				if isMatching {
+					return login, password, true
+				}
+
+				isDefault = true
+				isDefaultFound = true
+			case "login":
+				if isMatching {
+					login = value
+				} else if isDefault {
+					defaultLogin = value
+				}
+				j++
+			case "password":
+				if isMatching {
+					password = value
+				} else if isDefault {
+					defaultPassword = value
+				}
+				j++
+			case "account":
+				j++
+			case "macdef":
+				// A macro definition ends with an empty line
+				for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+					i++
+				}
+
+				j = len(fields)
+			}
+		}
+	}
+
+	if isMatching {
+		return login, password, true
+	}
+
+	return defaultLogin, defaultPassword, isDefaultFound
+}
@@ -0,0 +1,220 @@
+package manager_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
+)
+
+const (
+	testUsername = "user"
+	testPassword = "secret"
+	testToken    = "token"
+	testRealm    = "artifacts"
+	testNonce    = "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+)
+
+// isDigestAuthorized verifies the digest authentication of a request against the test credentials.
+func isDigestAuthorized(r *http.Request) bool {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Digest ") {
+		return false
+	}
+
+	params := make(map[string]string)
+	for _, param := range strings.Split(strings.TrimPrefix(authorization, "Digest "), ", ") {
+		if i := strings.Index(param, "="); i > 0 {
+			params[param[:i]] = strings.Trim(param[i+1:], `"`)
+		}
+	}
+
+	h := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+
+	ha1 := h(testUsername + ":" + testRealm + ":" + testPassword)
+	ha2 := h(r.Method + ":" + params["uri"])
+	response := h(ha1 + ":" + testNonce + ":" + params["nc"] + ":" + params["cnonce"] + ":auth:" + ha2)
+
+	return params["username"] == testUsername && params["response"] == response
+}
+
+func TestAuthentication(t *testing.T) {
+	const size = 256 * 1024
+
+	content := newTestContent(size)
+
+	netrc := "machine example.com login other password other\n" +
+		"machine localhost login " + testUsername + " password " + testPassword + "\n" +
+		"macdef init\n" +
+		"machine 127.0.0.1 login nobody\n" +
+		"\n" +
+		"machine 127.0.0.1\n" +
+		"\tlogin " + testUsername + "\n" +
+		"\tpassword " + testPassword + "\n" +
+		"default login anonymous password anonymous\n"
+
+	basicAuthorized := func(r *http.Request) bool {
+		username, password, ok := r.BasicAuth()
+		return ok && username == testUsername && password == testPassword
+	}
+
+	var testCases = []struct {
+		name         string
+		options      []manager.ConfigOption
+		isNetrc      bool
+		isDigest     bool
+		isRedirected bool
+		isAuthorized func(r *http.Request) bool
+		wantErr      bool
+	}{
+		{
+			name:         "Basic",
+			options:      []manager.ConfigOption{manager.BasicAuth(testUsername, testPassword)},
+			isAuthorized: basicAuthorized,
+		},
+		{
+			name:    "Bearer token",
+			options: []manager.ConfigOption{manager.BearerToken(testToken)},
+			isAuthorized: func(r *http.Request) bool {
+				return r.Header.Get("Authorization") == "Bearer "+testToken
+			},
+		},
+		{
+			name:         "Digest",
+			options:      []manager.ConfigOption{manager.BasicAuth(testUsername, testPassword)},
+			isDigest:     true,
+			isAuthorized: isDigestAuthorized,
+		},
+		{
+			name:         "Digest redirected",
+			isNetrc:      true,
+			isDigest:     true,
+			isRedirected: true,
+			isAuthorized: isDigestAuthorized,
+		},
+		{
+			name:         "Basic redirected",
+			isNetrc:      true,
+			isRedirected: true,
+			isAuthorized: basicAuthorized,
+		},
+		{
+			name:         ".netrc",
+			isNetrc:      true,
+			isAuthorized: basicAuthorized,
+		},
+		{
+			name:         "Wrong password",
+			options:      []manager.ConfigOption{manager.BasicAuth(testUsername, "wrong")},
+			isDigest:     true,
+			isAuthorized: isDigestAuthorized,
+			wantErr:      true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var isChallengeSent, isPreemptive int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// The user name and password are only sent once the server asks for them
+				if _, _, ok := r.BasicAuth(); ok && atomic.LoadInt32(&isChallengeSent) == 0 {
+					atomic.StoreInt32(&isPreemptive, 1)
+				}
+
+				if !testCase.isAuthorized(r) {
+					if testCase.isDigest {
+						w.Header().Set("WWW-Authenticate", `Digest realm="`+testRealm+`", qop="auth,auth-int", nonce="`+testNonce+`", opaque="5ccc069c403ebaf9f0171e9517f40e41", algorithm=MD5`)
+					} else {
+						w.Header().Set("WWW-Authenticate", `Basic realm="`+testRealm+`"`)
+					}
+
+					atomic.StoreInt32(&isChallengeSent, 1)
+					w.WriteHeader(http.StatusUnauthorized)
+
+					return
+				}
+
+				http.ServeContent(w, r, "download.bin", time.Time{}, bytes.NewReader(content))
+			}))
+			defer server.Close()
+
+			// The challenge is sent by another host the download URL redirects to
+			downloadURL := server.URL + "/download.bin"
+			if testCase.isRedirected {
+				origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					http.Redirect(w, r, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)+r.URL.Path, http.StatusFound)
+				}))
+				defer origin.Close()
+
+				downloadURL = origin.URL + "/download.bin"
+			}
+
+			directory := newTestDirectory(t)
+
+			// The .netrc file is looked up by host without the port
+			netrcFile := filepath.Join(directory, ".netrc")
+			if testCase.isNetrc {
+				if err := ioutil.WriteFile(netrcFile, []byte(netrc), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			d, err := manager.NewDownload(append([]manager.ConfigOption{
+				manager.DownloadURL(downloadURL),
+				manager.NrOfConcurrentDownload(4),
+				manager.SaveDirectory(directory),
+				manager.SaveFileName("download.bin"),
+				manager.NetrcFile(netrcFile)},
+				testCase.options...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = d.Initialize()
+			if testCase.wantErr {
+				var statusErr *manager.HTTPStatusError
+				if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+					t.Fatalf("Want unauthorized error, got %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Every range request is authorized
+			if err = d.Start(); err != nil {
+				t.Fatal(err)
+			}
+
+			get, err := ioutil.ReadFile(filepath.Join(directory, "download.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(content, get) {
+				t.Errorf("Want %d bytes of content, got %d bytes that differ", len(content), len(get))
+			}
+
+			if atomic.LoadInt32(&isPreemptive) != 0 {
+				t.Error("Want basic credentials sent after the challenge, got sent before")
+			}
+		})
+	}
+}
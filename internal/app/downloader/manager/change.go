@@ -0,0 +1,170 @@
+package manager
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// maxNrOfRestarts is the number of times a download restarts in a row because the remote file changed.
+const maxNrOfRestarts = 3
+
+// ChangePolicy decides what happens to a download when the remote file changes before it is complete.
+type ChangePolicy int
+
+const (
+	// FailOnChange fails the download with ErrRemoteFileChanged.
+	FailOnChange ChangePolicy = iota
+
+	// RestartOnChange discards the bytes downloaded and downloads the changed file from the start.
+	RestartOnChange
+)
+
+func (p ChangePolicy) String() string {
+	policyStr := ""
+
+	switch p {
+	case FailOnChange:
+		policyStr = "fail"
+	case RestartOnChange:
+		policyStr = "restart"
+	}
+
+	return policyStr
+}
+
+// ChangePolicy returns what happens to the download when the remote file changes before it is complete.
+func (d *Download) ChangePolicy() ChangePolicy {
+	return d.changePolicy
+}
+
+// SetChangePolicy sets what happens to the download when the remote file changes before it is complete
+// and returns a non nil error if failed to set.
+func (d *Download) SetChangePolicy(changePolicy ChangePolicy) error {
+	if changePolicy != FailOnChange && changePolicy != RestartOnChange {
+		return errors.New("unknown change policy")
+	}
+
+	d.changePolicy = changePolicy
+
+	return nil
+}
+
+// ifRange returns the validator of the remote file sent in the If-Range header of range requests,
+// so the server sends the whole changed file instead of a range of it if the remote file changed.
+// A weak entity tag cannot be used, in which case the last modified date is used.
+func (d *Download) ifRange() string {
+	if eTag := d.ETag(); eTag != "" && !strings.HasPrefix(eTag, "W/") {
+		return eTag
+	}
+
+	return d.LastModified()
+}
+
+// isRemoteFileChanged returns a boolean indicating whether the validators of the response
+// do not match the validators of the remote file when the download started.
+// The last modified date is only compared if there is no entity tag to compare.
+func (d *Download) isRemoteFileChanged() bool {
+	eTag := d.response.Header.Get("ETag")
+	if eTag != "" && d.ETag() != "" {
+		return eTag != d.ETag()
+	}
+
+	lastModified := d.response.Header.Get("Last-Modified")
+
+	return lastModified != "" && d.LastModified() != "" && lastModified != d.LastModified()
+}
+
+// restartIfNeeded restarts the download from the start if it stopped with the error
+// because the remote file changed and the change policy is RestartOnChange,
+// or because the server ignored the range requests of the segments.
+// It returns the error of the last run.
+func (d *Download) restartIfNeeded(err error) error {
+	for i := 0; i < maxNrOfRestarts && !d.IsDownloadAborted(); i++ {
+		switch {
+		case errors.Is(err, ErrRemoteFileChanged) && d.ChangePolicy() == RestartOnChange:
+			err = d.restartDownload()
+		case errors.Is(err, errRangeIgnored):
+			err = d.restartSingleStream()
+		default:
+			return err
+		}
+	}
+
+	// The range requests are not retried further, and the download is not left stopped without failing
+	if errors.Is(err, errRangeIgnored) && !d.IsDownloadAborted() {
+		d.fail(err)
+	}
+
+	return err
+}
+
+// restartDownload discards the bytes downloaded and their segments,
+// probes the download URL again for the details of the changed file and starts the download again.
+func (d *Download) restartDownload() error {
+	d.Logger().Warn("Restarting download as the remote file changed", "url", d.DownloadURL())
+
+	if err := d.discardSegments(); err != nil {
+		return err
+	}
+
+	// The checksum provided by the server is the checksum of the old file
+	if d.isChecksumFromServer {
+		d.checksumAlgorithm = ""
+		d.checksum = nil
+		d.isChecksumFromServer = false
+	}
+
+	if err := d.probe(); err != nil {
+		return err
+	}
+
+	d.probeMirrors()
+
+	return d.startDownload()
+}
+
+// restartSingleStream discards the bytes downloaded and their segments
+// and downloads the whole file with a single request,
+// as the server ignores range requests despite announcing support for them.
+// The download cannot be paused or split for the rest of the session.
+func (d *Download) restartSingleStream() error {
+	d.Logger().Warn("Restarting download as a single stream as the server ignored the range requests",
+		"url", d.DownloadURL())
+
+	if err := d.discardSegments(); err != nil {
+		return err
+	}
+
+	_ = d.setIsConcurrentConnectionAllowed(notAllowed)
+	_ = d.setIsPauseAllowed(notAllowed)
+
+	return d.startDownload()
+}
+
+// discardFiles deletes the temporary files, the resume manifest and the placeholder file of an aborted download.
+func (d *Download) discardFiles() {
+	_ = d.discardSegments()
+	_ = d.removeManifest()
+	_ = os.Remove(d.SaveFullPath())
+
+	d.Logger().Debug("Discarded download files", "path", d.SaveFullPath())
+}
+
+// discardSegments deletes the temporary files of a stopped download and removes its segments.
+func (d *Download) discardSegments() error {
+	for _, tempFilePath := range d.tempFileList {
+		if err := os.Remove(tempFilePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	_ = d.setTempFileList(nil)
+	_ = d.setTempFileNameAppender(0)
+
+	d.childrenMu.Lock()
+	_ = d.setChildren(nil)
+	d.childrenMu.Unlock()
+
+	return nil
+}
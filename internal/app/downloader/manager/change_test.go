@@ -0,0 +1,134 @@
+package manager_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
+)
+
+func TestRemoteFileChange(t *testing.T) {
+	const (
+		size                   = 512 * 1024
+		nrOfConcurrentDownload = 4
+	)
+
+	oldContent := newTestContent(size)
+
+	newContent := newTestContent(size + 1000)
+	for i := range newContent {
+		newContent[i] ^= 0xff
+	}
+
+	var testCases = []struct {
+		name         string
+		changePolicy manager.ChangePolicy
+		eTag         bool
+		wantErr      error
+	}{
+		{name: "Fail on entity tag change", changePolicy: manager.FailOnChange, eTag: true, wantErr: manager.ErrRemoteFileChanged},
+		{name: "Fail on last modified change", changePolicy: manager.FailOnChange, wantErr: manager.ErrRemoteFileChanged},
+		{name: "Restart on entity tag change", changePolicy: manager.RestartOnChange, eTag: true},
+		{name: "Restart on last modified change", changePolicy: manager.RestartOnChange},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			g := newGate(32 * 1024)
+			var isChanged int32
+
+			// The server only sends the range if the If-Range header matches the current file
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				content, version, modTime := oldContent, `"v1"`, time.Unix(1600000000, 0)
+				if atomic.LoadInt32(&isChanged) == 1 {
+					content, version, modTime = newContent, `"v2"`, modTime.Add(time.Hour)
+				}
+
+				if testCase.eTag {
+					w.Header().Set("ETag", version)
+				}
+
+				if r.Header.Get("Range") != "" {
+					w = &gatedWriter{ResponseWriter: w, request: r, gate: g}
+				}
+
+				http.ServeContent(w, r, "download.bin", modTime, bytes.NewReader(content))
+			}))
+			defer server.Close()
+
+			directory := newTestDirectory(t)
+
+			d, err := manager.NewDownload(
+				manager.DownloadURL(server.URL+"/download.bin"),
+				manager.NrOfConcurrentDownload(nrOfConcurrentDownload),
+				manager.SaveDirectory(directory),
+				manager.SaveFileName("download.bin"),
+				manager.OnRemoteFileChange(testCase.changePolicy))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = d.Initialize(); err != nil {
+				t.Fatal(err)
+			}
+
+			started := make(chan error, 1)
+			go func() {
+				started <- d.Start()
+			}()
+
+			waitFor(t, func() bool {
+				return d.IsDownloadRunning() && d.BytesCompleted() == int64(nrOfConcurrentDownload*g.after)
+			})
+
+			if err = d.Pause(); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = <-started; err != nil {
+				t.Fatal(err)
+			}
+
+			// The remote file changes while the download is paused
+			atomic.StoreInt32(&isChanged, 1)
+			g.open()
+
+			if err = d.Resume(); !errors.Is(err, testCase.wantErr) {
+				t.Fatalf("Want error %v, got %v", testCase.wantErr, err)
+			}
+
+			if _, err = os.Stat(d.ManifestPath()); !os.IsNotExist(err) {
+				t.Errorf("Want resume manifest removed, got %v", err)
+			}
+
+			if testCase.wantErr != nil {
+				if !d.IsDownloadFailed() {
+					t.Errorf("Want download failed")
+				}
+
+				return
+			}
+
+			if !d.IsDownloadComplete() {
+				t.Fatalf("Want download complete")
+			}
+
+			get, err := ioutil.ReadFile(filepath.Join(directory, "download.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(newContent, get) {
+				t.Errorf("Want %d bytes of the changed content, got %d bytes that differ", len(newContent), len(get))
+			}
+		})
+	}
+}
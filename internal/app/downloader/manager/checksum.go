@@ -0,0 +1,137 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"os"
+)
+
+// HashAlgorithm is a hash algorithm used to verify the checksum of a download.
+// The values are the algorithm names used in the Digest and Repr-Digest headers.
+type HashAlgorithm string
+
+// Supported hash algorithms.
+const (
+	MD5    HashAlgorithm = "md5"
+	SHA1   HashAlgorithm = "sha"
+	SHA256 HashAlgorithm = "sha-256"
+	SHA512 HashAlgorithm = "sha-512"
+)
+
+// newHash returns a new hash of the algorithm, or nil if the algorithm is not supported.
+func (a HashAlgorithm) newHash() hash.Hash {
+	switch a {
+	case MD5:
+		return md5.New()
+	case SHA1:
+		return sha1.New()
+	case SHA256:
+		return sha256.New()
+	case SHA512:
+		return sha512.New()
+	}
+
+	return nil
+}
+
+// ChecksumAlgorithm returns the hash algorithm of the checksum the download is verified with,
+// or an empty string if it is not verified.
+func (d *Download) ChecksumAlgorithm() HashAlgorithm {
+	return d.checksumAlgorithm
+}
+
+// Checksum returns the hexadecimal checksum the download is verified with,
+// which is either set or provided by the server.
+func (d *Download) Checksum() string {
+	return hex.EncodeToString(d.checksum)
+}
+
+// SetChecksum sets the hexadecimal checksum the download file is verified with once it is downloaded
+// and returns a non nil error if failed to set.
+// A checksum set takes precedence over a checksum provided by the server.
+func (d *Download) SetChecksum(algorithm HashAlgorithm, checksum string) error {
+	decodedChecksum, err := hex.DecodeString(checksum)
+	if err != nil {
+		return errors.New("checksum is not hexadecimal")
+	}
+
+	return d.setChecksum(algorithm, decodedChecksum)
+}
+
+func (d *Download) setChecksum(algorithm HashAlgorithm, checksum []byte) error {
+	h := algorithm.newHash()
+	if h == nil {
+		return errors.New("unsupported hash algorithm: " + string(algorithm))
+	}
+
+	if len(checksum) != h.Size() {
+		return errors.New("checksum length does not match the hash algorithm " + string(algorithm))
+	}
+
+	d.checksumAlgorithm = algorithm
+	d.checksum = checksum
+
+	return nil
+}
+
+// startChecksum starts hashing the download from the first byte if it is verified with a checksum.
+func (d *Download) startChecksum() {
+	d.checksumMu.Lock()
+	defer d.checksumMu.Unlock()
+
+	d.checksumHash = d.checksumAlgorithm.newHash()
+	d.checksumOffset = 0
+}
+
+// hashWritten hashes bytes written at the offset of the download file while it streams in,
+// if they directly follow the bytes hashed so far.
+// Bytes written elsewhere are hashed from the file once the download is complete.
+func (d *Download) hashWritten(p []byte, offset int64) {
+	d.checksumMu.Lock()
+	defer d.checksumMu.Unlock()
+
+	if d.checksumHash == nil || offset != d.checksumOffset {
+		return
+	}
+
+	_, _ = d.checksumHash.Write(p)
+	d.checksumOffset += int64(len(p))
+}
+
+// verifyChecksum hashes the bytes of the download file not hashed while downloading
+// and compares the hash with the checksum.
+func (d *Download) verifyChecksum() error {
+	d.checksumMu.Lock()
+	defer d.checksumMu.Unlock()
+
+	if d.checksumHash == nil {
+		return nil
+	}
+
+	f, err := os.Open(d.SaveFullPath())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err = f.Seek(d.checksumOffset, io.SeekStart); err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(d.checksumHash, f); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(d.checksumHash.Sum(nil), d.checksum) {
+		return ErrChecksumMismatch
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package manager_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
+)
+
+func TestChecksum(t *testing.T) {
+	const size = 256 * 1024
+
+	content := newTestContent(size)
+	otherContent := newTestContent(size + 1)
+
+	sha256Sum := sha256.Sum256(content)
+	sha512Sum := sha512.Sum512(content)
+	md5Sum := md5.Sum(content)
+	otherSha256Sum := sha256.Sum256(otherContent)
+
+	var testCases = []struct {
+		name                   string
+		nrOfConcurrentDownload int
+		options                []manager.ConfigOption
+		header                 map[string]string
+		wantErr                error
+	}{
+		{
+			name:                   "Single connection",
+			nrOfConcurrentDownload: 1,
+			options:                []manager.ConfigOption{manager.Checksum(manager.SHA256, hex.EncodeToString(sha256Sum[:]))},
+		},
+		{
+			name:                   "Concurrent connections",
+			nrOfConcurrentDownload: 8,
+			options:                []manager.ConfigOption{manager.Checksum(manager.SHA512, hex.EncodeToString(sha512Sum[:]))},
+		},
+		{
+			name:                   "Mismatch",
+			nrOfConcurrentDownload: 4,
+			options:                []manager.ConfigOption{manager.Checksum(manager.SHA256, hex.EncodeToString(otherSha256Sum[:]))},
+			wantErr:                manager.ErrChecksumMismatch,
+		},
+		{
+			name:                   "Repr-Digest header",
+			nrOfConcurrentDownload: 4,
+			header:                 map[string]string{"Repr-Digest": "sha-256=:" + base64.StdEncoding.EncodeToString(sha256Sum[:]) + ":"},
+		},
+		{
+			name:                   "Digest header mismatch",
+			nrOfConcurrentDownload: 4,
+			header:                 map[string]string{"Digest": "SHA-256=" + base64.StdEncoding.EncodeToString(otherSha256Sum[:])},
+			wantErr:                manager.ErrChecksumMismatch,
+		},
+		{
+			name:                   "Content-MD5 header",
+			nrOfConcurrentDownload: 4,
+			header:                 map[string]string{"Content-MD5": base64.StdEncoding.EncodeToString(md5Sum[:])},
+		},
+		{
+			name:                   "Checksum set takes precedence",
+			nrOfConcurrentDownload: 4,
+			options:                []manager.ConfigOption{manager.Checksum(manager.MD5, hex.EncodeToString(md5Sum[:]))},
+			header:                 map[string]string{"Digest": "SHA-256=" + base64.StdEncoding.EncodeToString(otherSha256Sum[:])},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range testCase.header {
+					w.Header().Set(k, v)
+				}
+
+				http.ServeContent(w, r, "download.bin", time.Time{}, bytes.NewReader(content))
+			}))
+			defer server.Close()
+
+			d, err := manager.NewDownload(append([]manager.ConfigOption{
+				manager.DownloadURL(server.URL + "/download.bin"),
+				manager.NrOfConcurrentDownload(testCase.nrOfConcurrentDownload),
+				manager.SaveDirectory(newTestDirectory(t)),
+				manager.SaveFileName("download.bin")},
+				testCase.options...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = d.Initialize(); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = d.Start(); !errors.Is(err, testCase.wantErr) {
+				t.Fatalf("Want error %v, got %v", testCase.wantErr, err)
+			}
+
+			if d.IsDownloadComplete() != (testCase.wantErr == nil) {
+				t.Errorf("Want download complete %t, got %t", testCase.wantErr == nil, d.IsDownloadComplete())
+			}
+
+			if d.IsDownloadFailed() != (testCase.wantErr != nil) {
+				t.Errorf("Want download failed %t, got %t", testCase.wantErr != nil, d.IsDownloadFailed())
+			}
+		})
+	}
+}
+
+func TestSetChecksum(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		algorithm manager.HashAlgorithm
+		checksum  string
+		wantErr   bool
+	}{
+		{name: "Valid", algorithm: manager.MD5, checksum: "d41d8cd98f00b204e9800998ecf8427e"},
+		{name: "Not hexadecimal", algorithm: manager.MD5, checksum: "not hexadecimal", wantErr: true},
+		{name: "Wrong length", algorithm: manager.SHA256, checksum: "d41d8cd98f00b204e9800998ecf8427e", wantErr: true},
+		{name: "Unsupported algorithm", algorithm: "crc32", checksum: "00000000", wantErr: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			d, err := manager.NewDownload()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = d.SetChecksum(testCase.algorithm, testCase.checksum); (err != nil) != testCase.wantErr {
+				t.Errorf("Want error %t, got %v", testCase.wantErr, err)
+			}
+		})
+	}
+}
@@ -1,35 +1,28 @@
 package manager
 
-import "fmt"
-
-// DebugUrl prints download URL details.
+// DebugUrl logs download URL details at the debug level.
 func (d *Download) DebugUrl() {
-	// Debug print URL
-	fmt.Println("URL DEBUG:")
-	fmt.Println("URL scheme:", d.downloadURL.Scheme)
-	fmt.Println("URL host:", d.downloadURL.Host)
-	fmt.Println("URL Path:", d.downloadURL.Path)
-	fmt.Println()
+	d.Logger().Debug("Download URL",
+		"scheme", d.downloadURL.Scheme,
+		"host", d.downloadURL.Host,
+		"path", d.downloadURL.Path)
 }
 
-// DebugHeader prints download header details.
+// DebugHeader logs download header details at the debug level.
 func (d *Download) DebugHeader() {
-	// Debug print response header
-	fmt.Println("HEADER DEBUG:")
-	fmt.Println("Is download initialized:", d.isDownloadInitialized)
-	fmt.Println("Response content length:", d.response.ContentLength)
-	fmt.Println("Response headers:", d.response.Header)
-	fmt.Println("Response status code:", d.response.StatusCode)
-	fmt.Println()
+	d.Logger().Debug("Response header",
+		"initialized", d.isDownloadInitialized,
+		"contentLength", d.response.ContentLength,
+		"header", d.response.Header,
+		"status", d.response.StatusCode)
 }
 
-// DebugFileSize prints the file size in different units
+// DebugFileSize logs the file size in different units at the debug level.
 func (d *Download) DebugFileSize() {
-	// Debug print file size
-	fmt.Println("File size:")
-	fmt.Println("Bytes:", d.FileSize().Bytes())
-	fmt.Println("KB:", d.FileSize().KB())
-	fmt.Println("MB:", d.FileSize().MB())
-	fmt.Println("GB:", d.FileSize().GB())
-	fmt.Println("TB:", d.FileSize().TB())
+	d.Logger().Debug("File size",
+		"bytes", d.FileSize().Bytes(),
+		"KB", d.FileSize().KB(),
+		"MB", d.FileSize().MB(),
+		"GB", d.FileSize().GB(),
+		"TB", d.FileSize().TB())
 }
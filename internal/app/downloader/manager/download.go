@@ -8,72 +8,178 @@ import (
 	"math"
 	"net/http"
 	"os"
+	"path"
+	"sort"
 	"strconv"
 	"sync"
+	"time"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/util/bandwidth"
 )
 
-// sendHTTPRequest sends a HTTP request with custom header from parameter
+// sendHTTPRequest sends a HTTP request with the method and custom header from parameter
 // and stores the response in downloader.Response
-func (d *Download) sendHTTPRequest(header map[string]string) error {
+func (d *Download) sendHTTPRequest(method string, header map[string]string) error {
 	// Setup new context for stopping download
-	ctx, ctxCancel := context.WithCancel(context.Background())
-	_ = d.setCtx(ctx)
-	_ = d.setCtxCancel(ctxCancel)
+	// A segment derives its context from the parent so pausing or aborting the parent stops it
+	parentCtx := context.Background()
+	if d.parent != nil {
+		if ctx := d.parent.currentCtx(); ctx != nil {
+			parentCtx = ctx
+		}
+	}
+
+	ctx, err := d.newCtx(parentCtx)
+	if err != nil {
+		return err
+	}
 
 	// Setup request with the newly created instance's context
-	req, err := http.NewRequestWithContext(d.ctx,
-		http.MethodGet,
+	req, err := http.NewRequestWithContext(ctx,
+		method,
 		d.downloadURL.String(),
 		nil)
 	if err != nil {
 		return err
 	}
 
-	// Add custom header to the request
+	// Add the custom headers of the download, then the custom header from parameter
+	for k, values := range d.header {
+		req.Header[k] = append([]string(nil), values...)
+	}
+
+	if host := d.header.Get("Host"); host != "" {
+		req.Host = host
+	}
+
 	for k, v := range header {
-		req.Header.Add(k, v)
+		req.Header.Set(k, v)
 	}
 
+	// Credentials set or found in the .netrc file replace a custom Authorization header
+	d.authorize(req)
+
 	// Make the request to get the response header
-	response, err := http.DefaultClient.Do(req)
+	d.Logger().Debug("Request sent", "method", method, "url", req.URL, "range", req.Header.Get("Range"))
+
+	response, err := d.redirectingClient().Do(req)
 	if err != nil {
+		d.Logger().Debug("Request failed", "method", method, "url", req.URL, "error", err)
 		return err
 	}
+
+	// Send the request again answering the authentication challenge of the server
+	if d.isChallenged(response) {
+		_ = response.Body.Close()
+
+		req = req.Clone(ctx)
+		d.authorize(req)
+
+		if response, err = d.redirectingClient().Do(req); err != nil {
+			return err
+		}
+	}
+
+	d.Logger().Debug("Response received", "method", method, "url", response.Request.URL, "status", response.StatusCode)
+
 	_ = d.setResponse(response)
 
 	return nil
 }
 
+// probe retrieves the download details without downloading the file.
+// A HEAD request is sent first. If HEAD is not supported, or its response does not tell
+// the file size and whether partial requests are supported, a GET request for the first byte is sent instead.
+// The response body is always closed.
+// It returns ErrAborted if the download is aborted while probing.
+func (d *Download) probe() error {
+	err := d.probeResponse()
+
+	// A request cancelled by Abort fails with the error of its context instead
+	if d.IsDownloadAborted() {
+		return ErrAborted
+	}
+
+	return err
+}
+
+// probeResponse sends the probing requests and processes the response header.
+func (d *Download) probeResponse() error {
+	err := d.sendHTTPRequest(http.MethodHead, nil)
+	if err == nil {
+		_ = d.response.Body.Close()
+
+		if d.response.StatusCode == http.StatusOK {
+			if err = d.processRequestHeader(); err != nil {
+				return err
+			}
+
+			if d.IsConcurrentConnectionAllowed() != unknown && d.FileSize() > 0 {
+				return nil
+			}
+		}
+	}
+
+	// Request the first byte to confirm partial requests are supported
+	if err = d.sendHTTPRequest(http.MethodGet, map[string]string{"Range": "bytes=0-0"}); err != nil {
+		return err
+	}
+
+	// Close the body before the server sends the whole file if it ignored the range
+	_ = d.response.Body.Close()
+
+	if d.response.StatusCode != http.StatusOK && d.response.StatusCode != http.StatusPartialContent {
+		return &HTTPStatusError{StatusCode: d.response.StatusCode}
+	}
+
+	return d.processRequestHeader()
+}
+
 func (d *Download) processRequestHeader() error {
 	// Partial download reference:
 	// https://developer.mozilla.org/en-US/docs/Web/HTTP/Range_requests
 	//
-	// Content-Length header - If value is -1, we do not know the file size
-	// and unable to split it to download concurrently or resume download
-	//
-	// https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Content-Length
-	//
-	// Known content length
-	if d.response.ContentLength > 0 {
+	// 206 Partial Content to a range request - Partial request (concurrent download) / pause is supported
+	// and the file size is in the Content-Range header
+	if d.response.StatusCode == http.StatusPartialContent {
+		_, _, size, err := parseContentRange(d.response.Header.Get("Content-Range"))
+		if err != nil {
+			return err
+		}
+
+		if size > 0 {
+			_ = d.setIsConcurrentConnectionAllowed(allowed)
+			_ = d.setIsPauseAllowed(allowed)
+		} else {
+			// Unknown file size
+			_ = d.setIsConcurrentConnectionAllowed(notAllowed)
+			_ = d.setIsPauseAllowed(notAllowed)
+		}
+
+		// Update file size
+		_ = d.setFileSize(size)
+	} else if d.response.ContentLength > 0 {
+		// Content-Length header - If value is -1, we do not know the file size
+		// and unable to split it to download concurrently or resume download
+		//
+		// https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Content-Length
+		//
 		// If header "Accept-Ranges" exists and value its not none
 		// Then partial request (concurrent download) / pause is supported
 		//
 		// https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Accept-Ranges
-		acceptRanges, ok := d.response.Header["Accept-Ranges"]
-
-		// Accept-range exists
-		if ok {
-			// Concurrent download and pause is likely not allowed
-			if acceptRanges[0] == "none" {
-				_ = d.setIsConcurrentConnectionAllowed(notAllowed)
-				_ = d.setIsPauseAllowed(notAllowed)
-			} else {
-				// Allowed
-				_ = d.setIsConcurrentConnectionAllowed(allowed)
-				_ = d.setIsPauseAllowed(allowed)
-			}
+		acceptRanges := d.response.Header.Get("Accept-Ranges")
+
+		if d.response.Request.Header.Get("Range") != "" || acceptRanges == "none" {
+			// The server ignored the range request or does not accept ranges
+			_ = d.setIsConcurrentConnectionAllowed(notAllowed)
+			_ = d.setIsPauseAllowed(notAllowed)
+		} else if acceptRanges != "" {
+			// Allowed
+			_ = d.setIsConcurrentConnectionAllowed(allowed)
+			_ = d.setIsPauseAllowed(allowed)
 		} else {
-			// Unknown
+			// Unknown, to be confirmed by a range request
 			_ = d.setIsConcurrentConnectionAllowed(unknown)
 			_ = d.setIsPauseAllowed(unknown)
 		}
@@ -81,55 +187,82 @@ func (d *Download) processRequestHeader() error {
 		// Update file size
 		_ = d.setFileSize(d.response.ContentLength)
 	} else {
-		// Unknown content length
+		// Unknown content length, the file is streamed until the response ends
 		_ = d.setIsConcurrentConnectionAllowed(notAllowed)
 		_ = d.setIsPauseAllowed(notAllowed)
+		_ = d.setFileSize(-1)
+	}
+
+	// Validators to check whether the remote file changed when resuming
+	_ = d.setETag(d.response.Header.Get("ETag"))
+	_ = d.setLastModified(d.response.Header.Get("Last-Modified"))
+
+	// Checksum provided by the server, unless a checksum is set
+	// Content-MD5 is the checksum of the response body, which is only the whole file for 200 OK
+	if d.ChecksumAlgorithm() == "" {
+		algorithm, checksum := parseDigest(d.response.Header, d.response.StatusCode == http.StatusOK)
+		if algorithm != "" && d.setChecksum(algorithm, checksum) == nil {
+			d.isChecksumFromServer = true
+		}
 	}
 
+	// The segments download from the final URL after redirects
+	d.setRedirects()
+
 	// Get suggested default file name from header - Content-Disposition
-	// d.setDefaultFileName(...)
+	// or the last segment of the final URL path after redirects
+	defaultFileName := sanitizeFileName(parseContentDisposition(d.response.Header.Get("Content-Disposition")))
+	if defaultFileName == "" {
+		defaultFileName = sanitizeFileName(path.Base(d.response.Request.URL.Path))
+	}
+
+	if defaultFileName == "" {
+		defaultFileName = FallbackFileName
+	}
+
+	if err := d.setDefaultFileName(defaultFileName); err != nil {
+		return err
+	}
 
 	return nil
 }
 
-// startDownload starts the download.
+// startDownload splits the download into segments of byte ranges and starts the download.
 func (d *Download) startDownload() error {
-	fmt.Println("Starting download")
-
-	// Set the download as running
-	_ = d.setIsDownloadRunning(true)
-
-	contentLength := d.response.ContentLength
+	contentLength := d.FileSize().Bytes()
 	var currentByte int64 = 0
 
-	// Sync wait group
-	var wg sync.WaitGroup
+	// A download of unknown length is streamed by a single segment with an open ended range
+	if d.isStream() {
+		contentLength = streamRangeEnd + 1
+		_ = d.SetMaxNrOfConcurrentConnection(1)
+		_ = d.SetPreallocateFile(false)
+	}
 
 	if d.IsConcurrentConnectionAllowed() == notAllowed {
 		_ = d.SetMaxNrOfConcurrentConnection(1)
-	} else if d.IsConcurrentConnectionAllowed() == unknown {
-		// Unknown check if return is 206
 	}
 
-	for i := d.MaxNrOfConcurrentConnection(); i > 0; i-- {
-		// Create a new downloader with custom header to specify the custom bytes range for concurrent download
-		downloader, err := NewDownload(
-			SaveDirectory(d.SaveDirectory()),
-			SaveFileName(d.SaveFileName()),
-			NrOfConcurrentDownload(1),
-			DownloadURL(d.DownloadURL()))
-		if err != nil {
-			return err
-		}
+	// Each concurrent connection needs at least 1 byte to download
+	if int64(d.MaxNrOfConcurrentConnection()) > contentLength {
+		_ = d.SetMaxNrOfConcurrentConnection(int(contentLength))
+	}
 
-		// Get a temporary file name
-		file, err := downloader.createTemporaryFile()
-		if err != nil {
-			downloader.Abort()
+	d.Logger().Info("Starting download",
+		"url", d.DownloadURL(),
+		"size", d.FileSize().Bytes(),
+		"connections", d.MaxNrOfConcurrentConnection())
+
+	// Preallocate a single file written by all segments at their own offsets
+	// instead of a temporary file per segment combined at the end
+	if d.PreallocateFile() {
+		if _, err := d.createPreallocatedFile(); err != nil {
 			d.Abort()
 			return err
 		}
+	}
 
+	for i := d.MaxNrOfConcurrentConnection(); i > 0; i-- {
 		// Calculate bytes to get per concurrent connection
 		var bytesToGet int64
 
@@ -143,11 +276,14 @@ func (d *Download) startDownload() error {
 			bytesToGet = int64(math.Floor(float64(contentLength) / float64(i)))
 		}
 
-		// Send a HTTP request with custom header to get the new response header
-		if err = downloader.sendHTTPRequest(map[string]string{"Range": "bytes=" +
-			strconv.FormatInt(currentByte, 10) +
-			"-" +
-			strconv.FormatInt(currentByte+(bytesToGet-1), 10)}); err != nil {
+		// Create a new downloader to download the custom bytes range for concurrent download
+		downloader, err := d.newSegment(currentByte, currentByte+(bytesToGet-1))
+		if err != nil {
+			return err
+		}
+
+		if err = d.attachSegmentFile(downloader); err != nil {
+			d.Abort()
 			return err
 		}
 
@@ -155,88 +291,535 @@ func (d *Download) startDownload() error {
 		contentLength -= bytesToGet
 		currentByte += bytesToGet
 
-		// Check status code is 206 Partial Content before moving to next concurrent connection
-		// 200 - Partial download not supported
-		// 206 - Successful request
-		// 416 - Requested Range Not Satisfiable (Not of the requested range values overlap the available range)
-		if downloader.response.StatusCode != 206 {
-			fmt.Println("Return status code is not 206 partial download but:" +
-				strconv.Itoa(downloader.response.StatusCode))
+		_ = d.addChild(downloader)
+	}
+
+	// Save the segments so the download can continue after the process is restarted
+	if err := d.saveManifest(); err != nil {
+		return err
+	}
+
+	d.startChecksum()
+
+	// Flag the download as running
+	d.operationMu.Lock()
+	err := d.beginRun()
+	d.operationMu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return d.runSegments()
+}
+
+// newSegment creates a new download for the given inclusive byte range.
+// The caller is responsible for attaching the file written by the segment and adding it as a child.
+func (d *Download) newSegment(rangeStart, rangeEnd int64) (*Download, error) {
+	downloader, err := NewDownload(
+		SaveDirectory(d.SaveDirectory()),
+		SaveFileName(d.fileName()),
+		NrOfConcurrentDownload(1),
+		DownloadURL(d.DownloadURL()),
+		PreallocateFile(d.PreallocateFile()),
+		HTTPClient(d.HTTPClient()),
+		Log(d.Logger()),
+		MaxRedirects(d.MaxRedirects()),
+		CrossSchemeRedirect(d.IsCrossSchemeRedirectAllowed()))
+	if err != nil {
+		return nil, err
+	}
+
+	downloader.header = d.header
+	downloader.auth = d.auth
+
+	if err = downloader.setRange(rangeStart, rangeEnd); err != nil {
+		return nil, err
+	}
+	_ = downloader.setFileSize(d.FileSize().Bytes())
+
+	d.assignMirror(downloader)
+
+	return downloader, nil
+}
+
+// attachSegmentFile sets the file written by a segment,
+// which is either the preallocated file or a new temporary file of its own.
+func (d *Download) attachSegmentFile(segment *Download) error {
+	if d.PreallocateFile() {
+		segment.appendToTempFileList(d.tempFileList[0])
+		return nil
+	}
+
+	// Get a temporary file name
+	file, err := segment.createTemporaryFile()
+	if err != nil {
+		return err
+	}
+
+	// The temporary file is reopened by the segment every time it starts or resumes
+	_ = file.Close()
+
+	// Add to temp file list
+	d.appendToTempFileList(file.Name())
+
+	return nil
+}
+
+// splitSegment splits the remaining byte range of the segment with the most bytes remaining
+// and returns a new segment for its second half, to be downloaded by an idle connection.
+// The split segment stops downloading once it reaches the end of its shortened range.
+// A nil segment is returned if no segment has enough bytes remaining to be split.
+func (d *Download) splitSegment() (*Download, error) {
+	if d.IsConcurrentConnectionAllowed() == notAllowed {
+		return nil, nil
+	}
+
+	d.splitMu.Lock()
+	defer d.splitMu.Unlock()
+
+	var largest *Download
+	for _, segment := range d.segments() {
+		if largest == nil || segment.remainingBytes() > largest.remainingBytes() {
+			largest = segment
 		}
+	}
+
+	if largest == nil {
+		return nil, nil
+	}
+
+	// Hold the range of the split segment so it does not write past the split point
+	largest.rangeMu.Lock()
+	defer largest.rangeMu.Unlock()
 
-		fmt.Println("*********** Response status code:", downloader.response.StatusCode)
+	currentByte := largest.rangeStart + largest.BytesCompleted()
+	remainingBytes := largest.rangeEnd - currentByte + 1
+	if remainingBytes < 2*MinSegmentSplitSize {
+		return nil, nil
+	}
+
+	splitByte := currentByte + remainingBytes/2
+
+	segment, err := d.newSegment(splitByte, largest.rangeEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = d.attachSegmentFile(segment); err != nil {
+		return nil, err
+	}
+
+	largest.rangeEnd = splitByte - 1
+	_ = d.addChild(segment)
+
+	return segment, nil
+}
+
+// beginRun flags the download as running and sets up a new context
+// for stopping all segments of the current run.
+// It returns ErrAborted without flagging the download as running
+// if the download was aborted before the run began.
+// The caller must hold operationMu.
+func (d *Download) beginRun() error {
+	if d.IsDownloadAborted() {
+		d.Logger().Info("Download aborted", "url", d.DownloadURL())
+		return ErrAborted
+	}
 
-		// Add to temp file list
-		d.appendToTempFileList(file.Name())
+	if _, err := d.newCtx(context.Background()); err != nil {
+		return err
+	}
 
-		// Start the concurrent download with the new bytes range calculated above
-		// Use a closure in the below anonymous function / goroutine : (i int)
-		// to store the concurrent connection index (Same value as 'i' in the current for loop)
+	d.stopped = make(chan struct{})
+
+	_ = d.setIsDownloadRunning(true)
+
+	return nil
+}
+
+// runSegments downloads the remaining bytes of all incomplete segments concurrently
+// until all of them are complete, or the download is paused or aborted.
+// The download must have been flagged as running by beginRun.
+func (d *Download) runSegments() error {
+	defer close(d.stopped)
+
+	// Sync wait group
+	var wg sync.WaitGroup
+
+	// Only the first error is kept, it stops all other segments
+	var errOnce sync.Once
+	var segmentErr error
+
+	d.progressTracker.startRun(d.BytesCompleted(), d.segments())
+
+	for i, child := range d.segments() {
+		if child.isSegmentComplete() {
+			continue
+		}
 
 		// Track current running goroutine to its completion
 		wg.Add(1)
 
-		go func(i int) {
-			fmt.Println("***** Starting concurrent download:", i)
+		go func(i int, child *Download) {
+			// Set current goroutine as completed
+			defer wg.Done()
+
+			rangeStart, rangeEnd := child.segmentRange()
+			d.Logger().Debug("Segment started", "segment", i, "rangeStart", rangeStart, "rangeEnd", rangeEnd)
+
+			// Once its segment is complete, the connection takes over half of the slowest remaining segment
+			for segment := child; segment != nil; {
+				err := d.downloadSegmentWithRetry(segment)
+				if err == nil && d.ctx.Err() == nil {
+					segment, err = d.splitSegment()
+				} else {
+					segment = nil
+				}
+
+				if err != nil {
+					errOnce.Do(func() {
+						segmentErr = err
+						d.cancelCtx()
+					})
+				}
+			}
 
-			// DEBUG
-			downloader.DebugHeader()
+			d.Logger().Debug("Segment finished", "segment", i)
+		}(i, child)
+	}
 
-			// Write the specific data range to disk
-			//
-			// If file size is 1 GB and user has 1.2 GB disk space left,
-			// This might cause the space used to become 2 GB with 1 GB for save file and 1 GB for other temporary files.
-			// Could implement a read line by line and removing the read line from temporary files
-			// to allow user with 1.2 GB disk space download a 1 GB file concurrently
-			_, _ = io.Copy(file, downloader.response.Body)
+	// Wait for all tracked goroutines to be completed
+	// while saving the resume manifest and reporting the progress periodically
+	segmentsDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(segmentsDone)
+	}()
+
+	manifestTicker := time.NewTicker(ManifestSaveInterval)
+	defer manifestTicker.Stop()
+
+	var progressTick <-chan time.Time
+	if d.progressCallback != nil {
+		progressTicker := time.NewTicker(d.ProgressInterval())
+		defer progressTicker.Stop()
+
+		progressTick = progressTicker.C
+	}
 
-			// Close the temporary file
-			_ = file.Close()
+	for isWaiting := true; isWaiting; {
+		select {
+		case <-manifestTicker.C:
+			_ = d.saveManifest()
+		case <-progressTick:
+			d.reportProgress()
+		case <-segmentsDone:
+			isWaiting = false
+		}
+	}
+
+	// Record the bytes held by each temporary file so a resume continues at the right offset
+	for _, child := range d.segments() {
+		if err := child.syncBytesCompleted(); err != nil && segmentErr == nil {
+			segmentErr = err
+		}
+	}
 
-			fmt.Println("Closing concurrent download:", i)
+	// The size of a streamed download is known once its stream ended
+	if d.isStream() && segmentErr == nil && d.ctx.Err() == nil {
+		_ = d.setFileSize(d.BytesCompleted())
+	}
 
-			// Set current goroutine as completed
-			wg.Done()
-		}(i)
+	d.progressTracker.stopRun()
+	d.reportProgress()
 
-		// Stop adding concurrent connection if return is 200 instead of 206
-		if downloader.response.StatusCode == 200 {
-			break
+	// Stopped by Pause or Abort
+	if !d.stopRunning() {
+		if d.IsDownloadAborted() {
+			d.Logger().Info("Download aborted", "url", d.DownloadURL())
+			return ErrAborted
 		}
+
+		d.Logger().Info("Download paused", "url", d.DownloadURL(), "bytesCompleted", d.BytesCompleted())
+
+		return d.saveManifest()
 	}
 
-	// Wait for all tracked goroutines to be completed
-	wg.Wait()
+	d.cancelCtx()
+
+	if segmentErr != nil {
+		// The bytes downloaded cannot be resumed once the server turns out to ignore range requests
+		// The download is restarted as a single stream by restartIfNeeded and does not fail
+		if errors.Is(segmentErr, errRangeIgnored) {
+			_ = d.removeManifest()
+			return segmentErr
+		}
+
+		// Nor once the remote file changed
+		if errors.Is(segmentErr, ErrRemoteFileChanged) {
+			_ = d.removeManifest()
+
+			if d.ChangePolicy() == FailOnChange {
+				d.fail(segmentErr)
+			}
+
+			return segmentErr
+		}
+
+		d.Logger().Error("Download stopped", "url", d.DownloadURL(), "error", segmentErr)
+
+		_ = d.saveManifest()
+		return segmentErr
+	}
 
 	// Combine files and get the final download file
 	if err := d.combineFiles(); err != nil {
 		return err
 	}
 
+	// A corrupted download file cannot be resumed
+	err := d.verifyFileSize()
+	if err == nil {
+		err = d.verifyChecksum()
+	}
+
+	if err != nil {
+		_ = d.removeManifest()
+		d.fail(err)
+
+		return err
+	}
+
+	if err := d.removeManifest(); err != nil {
+		return err
+	}
+
 	// Set download as completed
 	d.complete()
 
 	return nil
 }
 
+// downloadSegment requests the remaining bytes of the segment range
+// and appends them to the temporary file of the segment.
+func (d *Download) downloadSegment() error {
+	if d.isSegmentComplete() {
+		return nil
+	}
+
+	if d.isStream() {
+		return d.downloadStream()
+	}
+
+	rangeStart, rangeEnd := d.segmentRange()
+	offset := rangeStart + d.BytesCompleted()
+
+	header := map[string]string{"Range": "bytes=" +
+		strconv.FormatInt(offset, 10) +
+		"-" +
+		strconv.FormatInt(rangeEnd, 10)}
+
+	// The range is only sent if the remote file is unchanged, otherwise the whole changed file is sent
+	if ifRange := d.ifRange(); ifRange != "" {
+		header["If-Range"] = ifRange
+	}
+
+	// Send a HTTP request with custom header to get the remaining bytes range
+	if err := d.sendHTTPRequest(http.MethodGet, header); err != nil {
+		return err
+	}
+	defer d.response.Body.Close()
+
+	if d.isRemoteFileChanged() {
+		return ErrRemoteFileChanged
+	}
+
+	// Check status code is 206 Partial Content
+	// 200 - Partial download not supported, only usable if the whole file was requested
+	// 206 - Successful request, if the Content-Range header matches the requested range
+	// 416 - Requested Range Not Satisfiable (Not of the requested range values overlap the available range)
+	isWholeFile := offset == 0 && rangeEnd == d.FileSize().Bytes()-1
+	if d.response.StatusCode == http.StatusOK && !isWholeFile {
+		return errRangeIgnored
+	}
+
+	if d.response.StatusCode == http.StatusPartialContent {
+		responseStart, _, size, err := parseContentRange(d.response.Header.Get("Content-Range"))
+		if err != nil || responseStart != offset || (size >= 0 && size != d.FileSize().Bytes()) {
+			return errRangeIgnored
+		}
+	}
+
+	if d.response.StatusCode != http.StatusPartialContent &&
+		!(d.response.StatusCode == http.StatusOK && isWholeFile) {
+		return &HTTPStatusError{StatusCode: d.response.StatusCode}
+	}
+
+	// Append to the bytes already in the temporary file,
+	// or write at the segment offset of the preallocated file
+	flag := os.O_WRONLY | os.O_APPEND
+	if d.PreallocateFile() {
+		flag = os.O_WRONLY
+	}
+
+	tempFile, err := os.OpenFile(d.tempFileList[0], flag, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer tempFile.Close()
+
+	// Write the specific data range to disk
+	//
+	// If file size is 1 GB and user has 1.2 GB disk space left,
+	// This might cause the space used to become 2 GB with 1 GB for save file and 1 GB for other temporary files.
+	// PreallocateFile avoids this by writing all segments into a single file.
+	// The segment stops once it reaches the end of its range, which moves back if the segment is split
+	body := &limitedReader{
+		ctx:      d.ctx,
+		reader:   d.response.Body,
+		limiters: []*bandwidth.Limiter{d.parent.speedLimiter, globalSpeedLimiter},
+	}
+
+	if _, err = io.Copy(&segmentWriter{segment: d, file: tempFile}, body); err != nil &&
+		err != errSegmentRangeReached {
+		return err
+	}
+
+	if !d.isSegmentComplete() {
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
+
+// downloadStream requests the whole file of a download of unknown length without a range
+// and writes it to the temporary file of the segment until the response ends.
+// As a stream cannot be resumed, it starts over from the first byte every time.
+func (d *Download) downloadStream() error {
+	_ = d.setBytesCompleted(0)
+	d.parent.startChecksum()
+
+	if err := d.sendHTTPRequest(http.MethodGet, nil); err != nil {
+		return err
+	}
+	defer d.response.Body.Close()
+
+	if d.response.StatusCode != http.StatusOK {
+		return &HTTPStatusError{StatusCode: d.response.StatusCode}
+	}
+
+	tempFile, err := os.OpenFile(d.tempFileList[0], os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer tempFile.Close()
+
+	body := &limitedReader{
+		ctx:      d.ctx,
+		reader:   d.response.Body,
+		limiters: []*bandwidth.Limiter{d.parent.speedLimiter, globalSpeedLimiter},
+	}
+
+	// A response cut short is reported as an unexpected EOF by the HTTP client
+	// if it is chunked or has a Content-Length header
+	if _, err = io.Copy(&segmentWriter{segment: d, file: tempFile}, body); err != nil {
+		return err
+	}
+
+	// The stream ended cleanly, so the range of the segment ends at the last byte written
+	d.rangeMu.Lock()
+	d.rangeEnd = d.rangeStart + d.BytesCompleted() - 1
+	d.rangeMu.Unlock()
+
+	return nil
+}
+
+// syncBytesCompleted updates the bytes completed of a segment with the size of its temporary file.
+// A segment writing to a preallocated file keeps its own count as the file size does not reflect it.
+func (d *Download) syncBytesCompleted() error {
+	if len(d.tempFileList) == 0 || d.PreallocateFile() {
+		return nil
+	}
+
+	fileInfo, err := os.Stat(d.tempFileList[0])
+	if err != nil {
+		return err
+	}
+
+	return d.setBytesCompleted(fileInfo.Size())
+}
+
+// streamRangeEnd is the open end of the range of a segment streaming a download of unknown length.
+const streamRangeEnd = math.MaxInt64 - 1
+
+// errRangeIgnored is returned by a segment when the server ignores its range request,
+// sending the whole file or a range other than the one requested.
+var errRangeIgnored = fmt.Errorf("%w: server ignored the range request", ErrRangeNotSupported)
+
+// errSegmentRangeReached stops the copy of a response once the segment reaches the end of its range.
+var errSegmentRangeReached = errors.New("segment range reached")
+
+// segmentWriter writes to the temporary file of a segment
+// and keeps track of the number of bytes written.
+// With a preallocated file, bytes are written at the current offset of the segment.
+type segmentWriter struct {
+	segment *Download
+	file    *os.File
+}
+
+func (w *segmentWriter) Write(p []byte) (int, error) {
+	w.segment.rangeMu.Lock()
+	defer w.segment.rangeMu.Unlock()
+
+	currentByte := w.segment.rangeStart + w.segment.BytesCompleted()
+
+	// Drop the bytes past the end of the range
+	var err error
+	if remainingBytes := w.segment.rangeEnd - currentByte + 1; int64(len(p)) > remainingBytes {
+		p = p[:remainingBytes]
+		err = errSegmentRangeReached
+	}
+
+	var n int
+	var writeErr error
+
+	if w.segment.PreallocateFile() {
+		n, writeErr = w.file.WriteAt(p, currentByte)
+	} else {
+		n, writeErr = w.file.Write(p)
+	}
+
+	w.segment.addBytesCompleted(int64(n))
+	w.segment.parent.hashWritten(p[:n], currentByte)
+
+	if writeErr != nil {
+		return n, writeErr
+	}
+
+	return n, err
+}
+
 // combineFiles combines all temporary files together to form the final download file.
 func (d *Download) combineFiles() error {
 	// Combine files
-	fmt.Println("Writing to file:")
+	tempFileList := d.orderedTempFileList()
+
+	d.Logger().Debug("Combining temporary files", "files", len(tempFileList), "path", d.SaveFullPath())
 
 	// Must have at least 1 temporary file
-	if len(d.tempFileList) < 1 {
+	if len(tempFileList) < 1 {
 		return errors.New("must have at least 1 temporary file")
 	}
 
 	// Open the first file to append other data onto it
-	firstTempFile, err := os.OpenFile(d.tempFileList[0], os.O_WRONLY|os.O_APPEND, os.ModePerm)
+	firstTempFile, err := os.OpenFile(tempFileList[0], os.O_WRONLY|os.O_APPEND, os.ModePerm)
 	if err != nil {
 		return err
 	}
 
 	// Loop through other temporary files and put the data into the first file
-	for _, v := range d.tempFileList[1:] {
+	for _, v := range tempFileList[1:] {
 		// Open current file
 		f, err := os.OpenFile(v, os.O_RDONLY, os.ModePerm)
 		if err != nil {
@@ -262,13 +845,47 @@ func (d *Download) combineFiles() error {
 	}
 
 	// Delete all temporary files
-	for _, v := range d.tempFileList[1:] {
+	for _, v := range tempFileList[1:] {
 		if err := os.Remove(v); err != nil {
 			return err
 		}
 	}
 
-	fmt.Println("Combine temporary files done")
+	d.Logger().Debug("Combined temporary files", "path", d.SaveFullPath())
 
 	return nil
 }
+
+// verifyFileSize returns ErrSizeMismatch if the size of the download file is not the size of the remote file.
+func (d *Download) verifyFileSize() error {
+	fileInfo, err := os.Stat(d.SaveFullPath())
+	if err != nil {
+		return err
+	}
+
+	if fileInfo.Size() != d.FileSize().Bytes() {
+		return fmt.Errorf("%w: downloaded %d bytes instead of %d bytes", ErrSizeMismatch, fileInfo.Size(), d.FileSize().Bytes())
+	}
+
+	return nil
+}
+
+// orderedTempFileList returns the temporary files ordered by the byte range of their segments,
+// as segments split while downloading are added at the end of the temporary file list.
+func (d *Download) orderedTempFileList() []string {
+	if d.PreallocateFile() {
+		return d.tempFileList
+	}
+
+	segments := d.segments()
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].rangeStart < segments[j].rangeStart
+	})
+
+	tempFileList := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		tempFileList = append(tempFileList, segment.tempFileList[0])
+	}
+
+	return tempFileList
+}
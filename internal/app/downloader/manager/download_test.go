@@ -0,0 +1,404 @@
+package manager_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
+)
+
+func TestSplitSegment(t *testing.T) {
+	const (
+		size                   = 16 * manager.MinSegmentSplitSize
+		nrOfConcurrentDownload = 2
+	)
+
+	for _, preallocateFile := range []bool{false, true} {
+		t.Run("Preallocate file "+strconv.FormatBool(preallocateFile), func(t *testing.T) {
+			content := newTestContent(size)
+
+			// Hold the first segment so the other connection takes over its remaining bytes
+			g := newGate(32 * 1024)
+			var nrOfRangeRequests int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Range") != "" {
+					atomic.AddInt32(&nrOfRangeRequests, 1)
+				}
+
+				if strings.HasPrefix(r.Header.Get("Range"), "bytes=0-") {
+					w = &gatedWriter{ResponseWriter: w, request: r, gate: g}
+				}
+
+				http.ServeContent(w, r, "download.bin", time.Time{}, bytes.NewReader(content))
+			}))
+			defer server.Close()
+
+			directory := newTestDirectory(t)
+
+			d, err := manager.NewDownload(
+				manager.DownloadURL(server.URL+"/download.bin"),
+				manager.NrOfConcurrentDownload(nrOfConcurrentDownload),
+				manager.SaveDirectory(directory),
+				manager.SaveFileName("download.bin"),
+				manager.PreallocateFile(preallocateFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = d.Initialize(); err != nil {
+				t.Fatal(err)
+			}
+
+			started := make(chan error, 1)
+			go func() {
+				started <- d.Start()
+			}()
+
+			// The idle connection splits the held segment at least once
+			<-g.blocked
+			waitFor(t, func() bool {
+				return atomic.LoadInt32(&nrOfRangeRequests) > nrOfConcurrentDownload
+			})
+
+			g.open()
+
+			if err = <-started; err != nil {
+				t.Fatal(err)
+			}
+
+			get, err := ioutil.ReadFile(filepath.Join(directory, "download.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(content, get) {
+				t.Errorf("Want %d bytes of content, got %d bytes that differ", len(content), len(get))
+			}
+		})
+	}
+}
+
+func TestSpeedLimit(t *testing.T) {
+	const (
+		size       = 256 * 1024
+		speedLimit = 128 * 1024
+	)
+
+	content := newTestContent(size)
+	server := newTestServer(content, nil)
+	defer server.Close()
+
+	d, err := manager.NewDownload(
+		manager.DownloadURL(server.URL+"/download.bin"),
+		manager.NrOfConcurrentDownload(4),
+		manager.SaveDirectory(newTestDirectory(t)),
+		manager.SaveFileName("download.bin"),
+		manager.SpeedLimit(speedLimit))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+
+	if err = d.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first second of bytes is allowed straight away
+	if get := time.Since(start); get < 900*time.Millisecond {
+		t.Errorf("Want download limited to %d bytes per second, took %s for %d bytes", speedLimit, get, size)
+	}
+
+	if err = d.SetSpeedLimit(-1); err == nil {
+		t.Errorf("Want error setting a negative speed limit")
+	}
+}
+
+func TestRangeIgnored(t *testing.T) {
+	const size = 512 * 1024
+
+	var testCases = []struct {
+		name          string
+		modifyRequest func(r *http.Request)
+	}{
+		{
+			name: "Whole file sent",
+			modifyRequest: func(r *http.Request) {
+				r.Header.Del("Range")
+			},
+		},
+		{
+			name: "Content-Range mismatch",
+			modifyRequest: func(r *http.Request) {
+				r.Header.Set("Range", "bytes=0-")
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			content := newTestContent(size)
+
+			// The server announces range support but does not send the ranges requested
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					testCase.modifyRequest(r)
+				}
+
+				http.ServeContent(w, r, "download.bin", time.Time{}, bytes.NewReader(content))
+			}))
+			defer server.Close()
+
+			directory := newTestDirectory(t)
+
+			d, err := manager.NewDownload(
+				manager.DownloadURL(server.URL+"/download.bin"),
+				manager.NrOfConcurrentDownload(4),
+				manager.SaveDirectory(directory),
+				manager.SaveFileName("download.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = d.Initialize(); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = d.Start(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !d.IsDownloadComplete() || d.IsDownloadFailed() {
+				t.Errorf("Want download complete and not failed, got complete %v and failed %v",
+					d.IsDownloadComplete(), d.IsDownloadFailed())
+			}
+
+			if get := d.IsConcurrentConnectionAllowed().String(); get != "not allowed" {
+				t.Errorf("Want concurrent connection not allowed, got %s", get)
+			}
+
+			if get := d.IsPauseAllowed().String(); get != "not allowed" {
+				t.Errorf("Want pause not allowed, got %s", get)
+			}
+
+			// Only the download file is left
+			files, err := ioutil.ReadDir(directory)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(files) != 1 {
+				t.Errorf("Want 1 file in the save directory, got %d", len(files))
+			}
+
+			get, err := ioutil.ReadFile(filepath.Join(directory, "download.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(content, get) {
+				t.Errorf("Want %d bytes of content, got %d bytes that differ", len(content), len(get))
+			}
+		})
+	}
+}
+
+func TestStream(t *testing.T) {
+	const size = 256 * 1024
+
+	content := newTestContent(size)
+
+	// writeChunks writes the content in chunks so the response has no Content-Length header
+	writeChunks := func(w http.ResponseWriter, content []byte) {
+		for len(content) > 0 {
+			n := 16 * 1024
+			if n > len(content) {
+				n = len(content)
+			}
+
+			_, _ = w.Write(content[:n])
+			w.(http.Flusher).Flush()
+			content = content[n:]
+		}
+	}
+
+	var testCases = []struct {
+		name    string
+		content []byte
+		handler func(w http.ResponseWriter, r *http.Request, nrOfRequest int32)
+	}{
+		{
+			name:    "Chunked",
+			content: content,
+			handler: func(w http.ResponseWriter, r *http.Request, nrOfRequest int32) {
+				writeChunks(w, content)
+			},
+		},
+		{
+			name:    "Closed connection",
+			content: content,
+			handler: func(w http.ResponseWriter, r *http.Request, nrOfRequest int32) {
+				conn, buf, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+
+				_, _ = buf.WriteString("HTTP/1.1 200 OK\r\nConnection: close\r\n\r\n")
+				_, _ = buf.Write(content)
+				_ = buf.Flush()
+			},
+		},
+		{
+			name:    "Cut short and retried",
+			content: content,
+			handler: func(w http.ResponseWriter, r *http.Request, nrOfRequest int32) {
+				if nrOfRequest == 1 {
+					writeChunks(w, content[:size/2])
+					panic(http.ErrAbortHandler)
+				}
+
+				writeChunks(w, content)
+			},
+		},
+		{
+			name:    "Empty",
+			content: []byte{},
+			handler: func(w http.ResponseWriter, r *http.Request, nrOfRequest int32) {},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var nrOfRequests int32
+
+			// The file is generated on the fly, so its length is unknown and ranges are not supported
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet || r.Header.Get("Range") != "" {
+					w.Header().Set("Content-Type", "application/octet-stream")
+					w.(http.Flusher).Flush()
+					return
+				}
+
+				testCase.handler(w, r, atomic.AddInt32(&nrOfRequests, 1))
+			}))
+			defer server.Close()
+
+			directory := newTestDirectory(t)
+
+			var last manager.Progress
+
+			d, err := manager.NewDownload(
+				manager.DownloadURL(server.URL+"/download.bin"),
+				manager.NrOfConcurrentDownload(4),
+				manager.SaveDirectory(directory),
+				manager.SaveFileName("download.bin"),
+				manager.PreallocateFile(true),
+				manager.RetryBackoff(time.Millisecond, 10*time.Millisecond),
+				manager.OnProgress(func(p manager.Progress) {
+					last = p
+				}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = d.Initialize(); err != nil {
+				t.Fatal(err)
+			}
+
+			if d.FileSize().Bytes() != -1 {
+				t.Errorf("Want unknown file size, got %d", d.FileSize().Bytes())
+			}
+
+			if err = d.Start(); err != nil {
+				t.Fatal(err)
+			}
+
+			wantSize := int64(len(testCase.content))
+
+			if !d.IsDownloadComplete() || d.FileSize().Bytes() != wantSize {
+				t.Errorf("Want download complete with file size %d, got %d", wantSize, d.FileSize().Bytes())
+			}
+
+			if last.BytesCompleted != wantSize || last.FileSize != wantSize {
+				t.Errorf("Want %d bytes completed of %d, got %d of %d", wantSize, wantSize, last.BytesCompleted, last.FileSize)
+			}
+
+			get, err := ioutil.ReadFile(filepath.Join(directory, "download.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(testCase.content, get) {
+				t.Errorf("Want %d bytes of content, got %d bytes that differ", len(testCase.content), len(get))
+			}
+		})
+	}
+}
+
+// countingTransport counts the requests sent through it.
+type countingTransport struct {
+	nrOfRequests int32
+}
+
+func (t *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.nrOfRequests, 1)
+
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestTransport(t *testing.T) {
+	const size = 256 * 1024
+
+	content := newTestContent(size)
+	var nrOfRequests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&nrOfRequests, 1)
+		http.ServeContent(w, r, "download.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	transport := &countingTransport{}
+
+	d, err := manager.NewDownload(
+		manager.DownloadURL(server.URL+"/download.bin"),
+		manager.Mirrors(server.URL+"/mirror/download.bin"),
+		manager.NrOfConcurrentDownload(4),
+		manager.SaveDirectory(newTestDirectory(t)),
+		manager.SaveFileName("download.bin"),
+		manager.Transport(transport))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The probes and the requests of all segments are sent through the transport
+	if get := atomic.LoadInt32(&transport.nrOfRequests); get != atomic.LoadInt32(&nrOfRequests) || get < 6 {
+		t.Errorf("Want all %d requests sent through the transport, got %d", nrOfRequests, get)
+	}
+
+	if err = d.SetHTTPClient(nil); err == nil {
+		t.Errorf("Want error setting a nil HTTP client")
+	}
+}
@@ -0,0 +1,65 @@
+package manager
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/util/file"
+)
+
+// ErrRangeNotSupported is returned when the download server does not support range requests,
+// so the download cannot be paused or downloaded from a mirror.
+var ErrRangeNotSupported = errors.New("range requests are not supported by the download server")
+
+// ErrAlreadyStarted is returned when starting a download that has been started before.
+var ErrAlreadyStarted = errors.New("download has already started")
+
+// ErrDownloadRunning is returned when initializing or queueing a download that is running.
+var ErrDownloadRunning = errors.New("download is currently running")
+
+// ErrNotRunning is returned when pausing a download that is not running.
+var ErrNotRunning = errors.New("download is not running")
+
+// ErrNotPaused is returned when resuming a download that is not paused.
+var ErrNotPaused = errors.New("download is not paused")
+
+// ErrAborted is returned by Start and Resume when the download is aborted while running.
+var ErrAborted = errors.New("download has been aborted")
+
+// ErrDirectoryNotFound is returned when the save directory does not exist.
+var ErrDirectoryNotFound = file.ErrDirectoryNotFound
+
+// ErrSizeMismatch is returned when the size of a downloaded file or a mirror
+// does not match the size of the remote file.
+var ErrSizeMismatch = errors.New("file size does not match")
+
+// ErrChecksumMismatch is returned when the checksum of a downloaded file does not match the expected checksum.
+var ErrChecksumMismatch = errors.New("checksum of the downloaded file does not match")
+
+// ErrRemoteFileChanged is returned when the remote file changed before the download is complete,
+// so the bytes downloaded before cannot be combined with the remaining bytes.
+var ErrRemoteFileChanged = errors.New("remote file changed since the download started")
+
+// ErrRedirectNotAllowed is returned when a request is redirected more often than allowed
+// or to another scheme when cross scheme redirects are not allowed.
+var ErrRedirectNotAllowed = errors.New("redirect not allowed")
+
+// ErrAlreadyQueued is returned when adding a download to a queue it is already in.
+var ErrAlreadyQueued = errors.New("download is already in the queue")
+
+// ErrNotQueued is returned when changing a download in a queue it is not in,
+// and reported for a download removed from the queue before it finished.
+var ErrNotQueued = errors.New("download is not in the queue")
+
+// HTTPStatusError is returned when the server responds with an unexpected status code.
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return "unexpected response status code " +
+		strconv.Itoa(e.StatusCode) +
+		" " +
+		http.StatusText(e.StatusCode)
+}
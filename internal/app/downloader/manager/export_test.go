@@ -0,0 +1,4 @@
+package manager
+
+// IsTransientError exports isTransientError for the tests.
+var IsTransientError = isTransientError
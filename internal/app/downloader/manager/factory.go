@@ -1,11 +1,30 @@
 package manager
 
+import (
+	"net/http"
+	"time"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/util/bandwidth"
+)
+
 // ConfigOption is the signature of functional option for Start.
 type ConfigOption func(d *Download) error
 
 // NewDownload create and returns a new Start instance with configurations from the parameter input.
 func NewDownload(configurations ...ConfigOption) (*Download, error) {
-	download := &Download{}
+	download := &Download{
+		maxNrOfConcurrentConnection:  1,
+		speedLimiter:                 bandwidth.NewLimiter(0),
+		header:                       make(http.Header),
+		maxRedirects:                 DefaultMaxRedirects,
+		isCrossSchemeRedirectAllowed: true,
+		auth:                         &authentication{},
+		progressInterval:             DefaultProgressInterval,
+		maxRetries:                   DefaultMaxRetries,
+		retryMinBackoff:              DefaultRetryMinBackoff,
+		retryMaxBackoff:              DefaultRetryMaxBackoff,
+		retryJitter:                  DefaultRetryJitter,
+	}
 
 	for _, configuration := range configurations {
 		err := configuration(download)
@@ -16,9 +35,11 @@ func NewDownload(configurations ...ConfigOption) (*Download, error) {
 	}
 
 	// Update save full path after updating save directory and save file name
-	err := download.setSaveFullPath()
-	if err != nil {
-		return nil, err
+	// Without a save file name, it is updated with the default file name provided by the server in Initialize
+	if download.SaveFileName() != "" {
+		if err := download.setSaveFullPath(); err != nil {
+			return nil, err
+		}
 	}
 
 	return download, nil
@@ -33,6 +54,13 @@ func DownloadURL(downloadURL string) ConfigOption {
 	}
 }
 
+// Mirrors allows setting the mirror URLs serving the same file as the download URL.
+func Mirrors(mirrors ...string) ConfigOption {
+	return func(d *Download) error {
+		return d.SetMirrors(mirrors)
+	}
+}
+
 // NrOfConcurrentDownload allows setting the value of number of concurrent download.
 func NrOfConcurrentDownload(nrOfConcurrentDownload int) ConfigOption {
 	return func(d *Download) error {
@@ -53,3 +81,143 @@ func SaveFileName(saveFileName string) ConfigOption {
 		return d.SetSaveFileName(saveFileName)
 	}
 }
+
+// PreallocateFile allows setting whether the download file is preallocated and written by all segments at their offsets.
+func PreallocateFile(preallocateFile bool) ConfigOption {
+	return func(d *Download) error {
+		return d.SetPreallocateFile(preallocateFile)
+	}
+}
+
+// MaxRetries allows setting the number of times a failed segment is retried without making progress.
+func MaxRetries(maxRetries int) ConfigOption {
+	return func(d *Download) error {
+		return d.SetMaxRetries(maxRetries)
+	}
+}
+
+// RetryBackoff allows setting the minimum and maximum delay between retries of a failed segment.
+func RetryBackoff(minBackoff, maxBackoff time.Duration) ConfigOption {
+	return func(d *Download) error {
+		return d.SetRetryBackoff(minBackoff, maxBackoff)
+	}
+}
+
+// RetryJitter allows setting the fraction of the retry delay that is randomized.
+func RetryJitter(retryJitter float64) ConfigOption {
+	return func(d *Download) error {
+		return d.SetRetryJitter(retryJitter)
+	}
+}
+
+// OnProgress allows setting the function called with the progress of the download while it is running.
+func OnProgress(progressCallback func(Progress)) ConfigOption {
+	return func(d *Download) error {
+		return d.SetProgressCallback(progressCallback)
+	}
+}
+
+// ProgressInterval allows setting the interval at which the progress of a running download is reported.
+func ProgressInterval(progressInterval time.Duration) ConfigOption {
+	return func(d *Download) error {
+		return d.SetProgressInterval(progressInterval)
+	}
+}
+
+// HTTPClient allows setting the HTTP client sending the requests of the download and its segments.
+func HTTPClient(httpClient *http.Client) ConfigOption {
+	return func(d *Download) error {
+		return d.SetHTTPClient(httpClient)
+	}
+}
+
+// Transport allows setting the transport sending the requests of the download and its segments.
+func Transport(transport http.RoundTripper) ConfigOption {
+	return func(d *Download) error {
+		return d.SetTransport(transport)
+	}
+}
+
+// Header allows setting a custom header sent with every request of the download.
+func Header(key, value string) ConfigOption {
+	return func(d *Download) error {
+		return d.SetHeader(key, value)
+	}
+}
+
+// UserAgent allows setting the User-Agent header sent with every request of the download.
+func UserAgent(userAgent string) ConfigOption {
+	return func(d *Download) error {
+		return d.SetUserAgent(userAgent)
+	}
+}
+
+// Referer allows setting the Referer header sent with every request of the download.
+func Referer(referer string) ConfigOption {
+	return func(d *Download) error {
+		return d.SetReferer(referer)
+	}
+}
+
+// MaxRedirects allows setting the number of redirects followed by a request.
+func MaxRedirects(maxRedirects int) ConfigOption {
+	return func(d *Download) error {
+		return d.SetMaxRedirects(maxRedirects)
+	}
+}
+
+// CrossSchemeRedirect allows setting whether a request follows redirects to another scheme.
+func CrossSchemeRedirect(isCrossSchemeRedirectAllowed bool) ConfigOption {
+	return func(d *Download) error {
+		return d.SetCrossSchemeRedirect(isCrossSchemeRedirectAllowed)
+	}
+}
+
+// Log allows setting the logger receiving the events of the download.
+func Log(logger Logger) ConfigOption {
+	return func(d *Download) error {
+		return d.SetLogger(logger)
+	}
+}
+
+// BasicAuth allows setting the user name and password sent to the download server.
+func BasicAuth(username, password string) ConfigOption {
+	return func(d *Download) error {
+		return d.SetBasicAuth(username, password)
+	}
+}
+
+// BearerToken allows setting the bearer token sent to the download server.
+func BearerToken(bearerToken string) ConfigOption {
+	return func(d *Download) error {
+		return d.SetBearerToken(bearerToken)
+	}
+}
+
+// NetrcFile allows setting the path of the .netrc file credentials are looked up in.
+func NetrcFile(netrcFile string) ConfigOption {
+	return func(d *Download) error {
+		return d.SetNetrcFile(netrcFile)
+	}
+}
+
+// SpeedLimit allows setting the maximum bytes per second used by all segments of the download.
+func SpeedLimit(bytesPerSecond int64) ConfigOption {
+	return func(d *Download) error {
+		return d.SetSpeedLimit(bytesPerSecond)
+	}
+}
+
+// OnRemoteFileChange allows setting what happens to the download when the remote file changes before it is complete.
+func OnRemoteFileChange(changePolicy ChangePolicy) ConfigOption {
+	return func(d *Download) error {
+		return d.SetChangePolicy(changePolicy)
+	}
+}
+
+// Checksum allows setting the hexadecimal checksum the download file is verified with.
+func Checksum(algorithm HashAlgorithm, checksum string) ConfigOption {
+	return func(d *Download) error {
+		return d.SetChecksum(algorithm, checksum)
+	}
+}
@@ -2,7 +2,9 @@ package manager
 
 import (
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/util/file"
 )
@@ -44,13 +46,45 @@ func (d *Download) createTemporaryFile() (*os.File, error) {
 	return tempFile, nil
 }
 
-// createPlaceHolderFile creates a placeholder file
-// with the name same as the download save file name.
-func (d *Download) createPlaceHolderFile() {
-	// Create a place holder file with the same name as the download save file and path
-	// Check file exist before creating the place holder file
-	placeholderFile, _ := os.Create(d.SaveFullPath())
-	_ = placeholderFile.Close()
+// createPreallocatedFile creates a temporary file with the size of the download
+// to be written by all segments at their own offsets.
+func (d *Download) createPreallocatedFile() (*os.File, error) {
+	tempFile, err := d.createTemporaryFile()
+	if err != nil {
+		return nil, err
+	}
+	defer tempFile.Close()
+
+	// Extend the file to the download size without writing to it
+	if err = tempFile.Truncate(d.FileSize().Bytes()); err != nil {
+		return nil, err
+	}
+
+	return tempFile, nil
+}
+
+// createPlaceHolderFile creates an empty file at the save path of the download,
+// which is replaced by the downloaded file once it is complete.
+// An existing file is never overwritten. The file name is numbered instead, such as "report (1).pdf",
+// and the save path is updated.
+func (d *Download) createPlaceHolderFile() error {
+	saveFullPath := d.SaveFullPath()
+	extension := filepath.Ext(saveFullPath)
+	name := strings.TrimSuffix(saveFullPath, extension)
+
+	for i := 1; ; i++ {
+		placeholderFile, err := os.OpenFile(saveFullPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, os.ModePerm)
+		if err == nil {
+			d.saveFullPath = saveFullPath
+			return placeholderFile.Close()
+		}
+
+		if !os.IsExist(err) {
+			return err
+		}
+
+		saveFullPath = name + " (" + strconv.Itoa(i) + ")" + extension
+	}
 }
 
 func (d *Download) incrementTempFileAppender() {
@@ -0,0 +1,201 @@
+package manager
+
+import (
+	"encoding/base64"
+	"errors"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseContentRange parses the value of a Content-Range header of a 206 Partial Content response,
+// such as "bytes 0-1023/146515", and returns the inclusive byte range and the complete size.
+// The size is -1 if the server does not know it ("bytes 0-1023/*").
+//
+// https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Content-Range
+func parseContentRange(contentRange string) (rangeStart, rangeEnd, size int64, err error) {
+	errInvalid := errors.New("invalid Content-Range header: " + contentRange)
+
+	contentRange = strings.TrimSpace(contentRange)
+	if !strings.HasPrefix(contentRange, "bytes ") {
+		return 0, 0, 0, errInvalid
+	}
+
+	byteRange, sizeStr, ok := cut(strings.TrimPrefix(contentRange, "bytes "), "/")
+	if !ok {
+		return 0, 0, 0, errInvalid
+	}
+
+	rangeStartStr, rangeEndStr, ok := cut(byteRange, "-")
+	if !ok {
+		return 0, 0, 0, errInvalid
+	}
+
+	if rangeStart, err = strconv.ParseInt(rangeStartStr, 10, 64); err != nil {
+		return 0, 0, 0, errInvalid
+	}
+
+	if rangeEnd, err = strconv.ParseInt(rangeEndStr, 10, 64); err != nil || rangeEnd < rangeStart {
+		return 0, 0, 0, errInvalid
+	}
+
+	size = -1
+	if sizeStr != "*" {
+		if size, err = strconv.ParseInt(sizeStr, 10, 64); err != nil || size <= rangeEnd {
+			return 0, 0, 0, errInvalid
+		}
+	}
+
+	return rangeStart, rangeEnd, size, nil
+}
+
+// cut slices s around the first instance of sep,
+// returning the text before and after sep and whether sep was found.
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+
+	return s, "", false
+}
+
+// parseContentDisposition returns the file name from the value of a Content-Disposition header,
+// or an empty string if there is none.
+// The RFC 5987 encoded filename* parameter takes precedence over the filename parameter.
+//
+// https://tools.ietf.org/html/rfc6266
+func parseContentDisposition(contentDisposition string) string {
+	if contentDisposition == "" {
+		return ""
+	}
+
+	// The mime package decodes UTF-8 encoded filename* parameters
+	if _, params, err := mime.ParseMediaType(contentDisposition); err == nil && params["filename"] != "" {
+		return params["filename"]
+	}
+
+	// Fall back to lenient parsing for headers not following the RFC,
+	// such as unquoted file names with spaces, and for ISO-8859-1 encoded filename* parameters
+	var fileName, extendedFileName string
+
+	for _, param := range strings.Split(contentDisposition, ";") {
+		key, value, ok := cut(strings.TrimSpace(param), "=")
+		if !ok {
+			continue
+		}
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "filename":
+			fileName = strings.Trim(strings.TrimSpace(value), `"`)
+		case "filename*":
+			extendedFileName = decodeExtendedValue(strings.TrimSpace(value))
+		}
+	}
+
+	if extendedFileName != "" {
+		return extendedFileName
+	}
+
+	return fileName
+}
+
+// decodeExtendedValue decodes a RFC 5987 extended parameter value in the form charset'language'value,
+// returning an empty string if it cannot be decoded.
+//
+// https://tools.ietf.org/html/rfc5987#section-3.2
+func decodeExtendedValue(value string) string {
+	charset, rest, ok := cut(value, "'")
+	if !ok {
+		return ""
+	}
+
+	// Skip the language
+	_, encoded, ok := cut(rest, "'")
+	if !ok {
+		return ""
+	}
+
+	decoded, err := url.PathUnescape(encoded)
+	if err != nil {
+		return ""
+	}
+
+	switch strings.ToLower(charset) {
+	case "utf-8":
+		if !utf8.ValidString(decoded) {
+			return ""
+		}
+
+		return decoded
+	case "iso-8859-1":
+		// Every ISO-8859-1 byte is the Unicode code point of the same value
+		runes := make([]rune, 0, len(decoded))
+		for i := 0; i < len(decoded); i++ {
+			runes = append(runes, rune(decoded[i]))
+		}
+
+		return string(runes)
+	}
+
+	return ""
+}
+
+// sanitizeFileName returns the last element of a file name provided by the server or the URL,
+// so it cannot change the save directory, or an empty string if it is not usable as a file name.
+// Leading dots are removed, so the server cannot save a hidden file such as .bashrc.
+func sanitizeFileName(fileName string) string {
+	fileName = path.Base(strings.ReplaceAll(strings.TrimSpace(fileName), `\`, "/"))
+
+	if fileName == "/" {
+		return ""
+	}
+
+	return strings.TrimLeft(fileName, ".")
+}
+
+// parseDigest returns the strongest checksum of the whole file provided by the server in the
+// Repr-Digest, Digest or Content-MD5 response headers, or an empty algorithm if there is none.
+// Content-MD5 is only used if it is the checksum of the whole file.
+//
+// https://www.rfc-editor.org/rfc/rfc9530
+// https://tools.ietf.org/html/rfc3230
+func parseDigest(header http.Header, isContentMD5WholeFile bool) (HashAlgorithm, []byte) {
+	checksums := make(map[HashAlgorithm][]byte)
+
+	// Digest: sha-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=, md5=...
+	// Repr-Digest: sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:
+	for _, headerName := range []string{"Digest", "Repr-Digest"} {
+		for _, value := range header.Values(headerName) {
+			for _, digest := range strings.Split(value, ",") {
+				algorithm, encoded, ok := cut(strings.TrimSpace(digest), "=")
+				if !ok {
+					continue
+				}
+
+				checksum, err := base64.StdEncoding.DecodeString(strings.Trim(strings.TrimSpace(encoded), ":"))
+				if err == nil {
+					checksums[HashAlgorithm(strings.ToLower(algorithm))] = checksum
+				}
+			}
+		}
+	}
+
+	if contentMD5 := header.Get("Content-MD5"); contentMD5 != "" && isContentMD5WholeFile {
+		if checksum, err := base64.StdEncoding.DecodeString(contentMD5); err == nil {
+			checksums[MD5] = checksum
+		}
+	}
+
+	// Strongest algorithm first
+	for _, algorithm := range []HashAlgorithm{SHA512, SHA256, SHA1, MD5} {
+		if checksum, ok := checksums[algorithm]; ok && len(checksum) == algorithm.newHash().Size() {
+			return algorithm, checksum
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,115 @@
+package manager_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
+)
+
+func TestHeader(t *testing.T) {
+	const (
+		size       = 512 * 1024
+		userAgent  = "QuantumDownloadManager-Test"
+		referer    = "https://example.com/downloads"
+		customName = "X-Download-Token"
+		customKey  = "abc123"
+	)
+
+	content := newTestContent(size)
+
+	// The server rejects every request without the headers
+	var nrOfRequests, nrOfRejected int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&nrOfRequests, 1)
+
+		if r.UserAgent() != userAgent || r.Referer() != referer || r.Header.Get(customName) != customKey {
+			atomic.AddInt32(&nrOfRejected, 1)
+			w.WriteHeader(http.StatusForbidden)
+
+			return
+		}
+
+		http.ServeContent(w, r, "download.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	directory := newTestDirectory(t)
+
+	d, err := manager.NewDownload(
+		manager.DownloadURL(server.URL+"/download.bin"),
+		manager.NrOfConcurrentDownload(4),
+		manager.SaveDirectory(directory),
+		manager.SaveFileName("download.bin"),
+		manager.UserAgent(userAgent),
+		manager.Referer(referer),
+		manager.Header(customName, customKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	get, err := ioutil.ReadFile(filepath.Join(directory, "download.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(content, get) {
+		t.Errorf("Want %d bytes of content, got %d bytes that differ", len(content), len(get))
+	}
+
+	if atomic.LoadInt32(&nrOfRejected) != 0 {
+		t.Errorf("Want every request sent with the headers, got %d of %d requests rejected", nrOfRejected, nrOfRequests)
+	}
+
+	if d.Header().Get(customName) != customKey {
+		t.Errorf("Want header %v, got %v", customKey, d.Header().Get(customName))
+	}
+}
+
+func TestSetHeader(t *testing.T) {
+	var testCases = []struct {
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{key: "Accept", value: "*/*"},
+		{key: "Cookie", value: "session=1"},
+		{key: "", value: "value", wantErr: true},
+		{key: "Bad Name", value: "value", wantErr: true},
+		{key: "X-Injected", value: "value\r\nRange: bytes=0-0", wantErr: true},
+		{key: "Range", value: "bytes=0-0", wantErr: true},
+		{key: "if-range", value: `"v1"`, wantErr: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.key, func(t *testing.T) {
+			d, err := manager.NewDownload()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = d.SetHeader(testCase.key, testCase.value)
+			if (err != nil) != testCase.wantErr {
+				t.Fatalf("Want error %v, got %v", testCase.wantErr, err)
+			}
+
+			if !testCase.wantErr && d.Header().Get(testCase.key) != testCase.value {
+				t.Errorf("Want %v, got %v", testCase.value, d.Header().Get(testCase.key))
+			}
+		})
+	}
+}
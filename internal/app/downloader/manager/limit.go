@@ -0,0 +1,50 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/util/bandwidth"
+)
+
+// globalSpeedLimiter limits the bandwidth used by all downloads of the process.
+var globalSpeedLimiter = bandwidth.NewLimiter(0)
+
+// GlobalSpeedLimit returns the maximum bytes per second used by all downloads together, 0 if unlimited.
+func GlobalSpeedLimit() int64 {
+	return globalSpeedLimiter.Limit()
+}
+
+// SetGlobalSpeedLimit sets the maximum bytes per second used by all downloads together,
+// 0 for unlimited, and returns a non nil error if failed to set.
+// It also applies to downloads that are currently running.
+func SetGlobalSpeedLimit(bytesPerSecond int64) error {
+	if bytesPerSecond < 0 {
+		return errors.New("speed limit cannot be negative")
+	}
+
+	globalSpeedLimiter.SetLimit(bytesPerSecond)
+
+	return nil
+}
+
+// limitedReader waits for the speed limiters after every read,
+// limiting the speed of all segments sharing the limiters.
+type limitedReader struct {
+	ctx      context.Context
+	reader   io.Reader
+	limiters []*bandwidth.Limiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+
+	for _, limiter := range r.limiters {
+		if waitErr := limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
@@ -0,0 +1,151 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Logger receives the leveled, structured events of a download,
+// such as requests sent, responses received, segments started and finished, retries and completion.
+// Each event has a message and alternating keys and values.
+//
+// The method set matches the leveled methods of log/slog.Logger so it can be used as a Logger.
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// LogLevel is the severity of a logged event.
+type LogLevel int
+
+const (
+	// DebugLevel logs requests, responses and segment details.
+	DebugLevel LogLevel = iota
+
+	// InfoLevel logs the start and completion of a download.
+	InfoLevel
+
+	// WarnLevel logs retries, mirror changes and restarts.
+	WarnLevel
+
+	// ErrorLevel logs failed downloads.
+	ErrorLevel
+)
+
+func (l LogLevel) String() string {
+	levelStr := ""
+
+	switch l {
+	case DebugLevel:
+		levelStr = "DEBUG"
+	case InfoLevel:
+		levelStr = "INFO"
+	case WarnLevel:
+		levelStr = "WARN"
+	case ErrorLevel:
+		levelStr = "ERROR"
+	}
+
+	return levelStr
+}
+
+// noopLogger discards all events. It is the logger of a download without a logger set.
+type noopLogger struct{}
+
+func (noopLogger) Debug(string, ...interface{}) {}
+func (noopLogger) Info(string, ...interface{})  {}
+func (noopLogger) Warn(string, ...interface{})  {}
+func (noopLogger) Error(string, ...interface{}) {}
+
+// textLogger writes events at or above its level as lines of text,
+// such as: 2006-01-02T15:04:05Z INFO Download complete path=/tmp/file bytes=1024
+type textLogger struct {
+	mu     sync.Mutex
+	writer io.Writer
+	level  LogLevel
+}
+
+// NewTextLogger returns a logger writing the events at or above the level to the writer as lines of text.
+func NewTextLogger(writer io.Writer, level LogLevel) Logger {
+	return &textLogger{writer: writer, level: level}
+}
+
+func (l *textLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.log(DebugLevel, msg, keysAndValues)
+}
+
+func (l *textLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.log(InfoLevel, msg, keysAndValues)
+}
+
+func (l *textLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.log(WarnLevel, msg, keysAndValues)
+}
+
+func (l *textLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.log(ErrorLevel, msg, keysAndValues)
+}
+
+func (l *textLogger) log(level LogLevel, msg string, keysAndValues []interface{}) {
+	if level < l.level {
+		return
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(time.Now().UTC().Format(time.RFC3339))
+	sb.WriteString(" ")
+	sb.WriteString(level.String())
+	sb.WriteString(" ")
+	sb.WriteString(msg)
+
+	for i := 0; i < len(keysAndValues); i += 2 {
+		sb.WriteString(" ")
+		sb.WriteString(fmt.Sprint(keysAndValues[i]))
+		sb.WriteString("=")
+
+		// A key without a value is logged with an empty value
+		if i+1 < len(keysAndValues) {
+			value := fmt.Sprint(keysAndValues[i+1])
+			if strings.ContainsAny(value, " \"=") || value == "" {
+				value = fmt.Sprintf("%q", value)
+			}
+
+			sb.WriteString(value)
+		}
+	}
+
+	sb.WriteString("\n")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, _ = io.WriteString(l.writer, sb.String())
+}
+
+// Logger returns the logger receiving the events of the download.
+func (d *Download) Logger() Logger {
+	if d.logger == nil {
+		return noopLogger{}
+	}
+
+	return d.logger
+}
+
+// SetLogger sets the logger receiving the events of the download and its segments
+// and returns a non nil error if failed to set.
+// Without a logger set, events are discarded.
+func (d *Download) SetLogger(logger Logger) error {
+	if logger == nil {
+		return errors.New("logger is nil")
+	}
+
+	d.logger = logger
+
+	return nil
+}
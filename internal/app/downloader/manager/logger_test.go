@@ -0,0 +1,137 @@
+package manager_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
+)
+
+// recordingLogger records the messages of the events logged at each level.
+type recordingLogger struct {
+	mu     sync.Mutex
+	events map[manager.LogLevel][]string
+}
+
+func (l *recordingLogger) record(level manager.LogLevel, msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.events == nil {
+		l.events = make(map[manager.LogLevel][]string)
+	}
+
+	l.events[level] = append(l.events[level], msg)
+}
+
+func (l *recordingLogger) Debug(msg string, _ ...interface{}) { l.record(manager.DebugLevel, msg) }
+func (l *recordingLogger) Info(msg string, _ ...interface{})  { l.record(manager.InfoLevel, msg) }
+func (l *recordingLogger) Warn(msg string, _ ...interface{})  { l.record(manager.WarnLevel, msg) }
+func (l *recordingLogger) Error(msg string, _ ...interface{}) { l.record(manager.ErrorLevel, msg) }
+
+func (l *recordingLogger) has(level manager.LogLevel, msg string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, event := range l.events[level] {
+		if event == msg {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestLogger(t *testing.T) {
+	content := newTestContent(256 * 1024)
+
+	server := newTestServer(content, nil)
+	defer server.Close()
+
+	logger := &recordingLogger{}
+
+	d, err := manager.NewDownload(
+		manager.DownloadURL(server.URL),
+		manager.NrOfConcurrentDownload(4),
+		manager.SaveDirectory(newTestDirectory(t)),
+		manager.SaveFileName("download.bin"),
+		manager.Log(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is written to the standard output while the download runs
+	stdout := os.Stdout
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Stdout = writer
+
+	if err = d.Initialize(); err == nil {
+		err = d.Start()
+	}
+
+	os.Stdout = stdout
+	_ = writer.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if output, _ := ioutil.ReadAll(reader); len(output) > 0 {
+		t.Errorf("Want no output, got %q", output)
+	}
+
+	var wantEvents = []struct {
+		level manager.LogLevel
+		msg   string
+	}{
+		{level: manager.DebugLevel, msg: "Request sent"},
+		{level: manager.DebugLevel, msg: "Response received"},
+		{level: manager.InfoLevel, msg: "Starting download"},
+		{level: manager.DebugLevel, msg: "Segment started"},
+		{level: manager.DebugLevel, msg: "Segment finished"},
+		{level: manager.DebugLevel, msg: "Combining temporary files"},
+		{level: manager.InfoLevel, msg: "Download complete"},
+	}
+
+	for _, want := range wantEvents {
+		if !logger.has(want.level, want.msg) {
+			t.Errorf("Want %v event %q logged", want.level, want.msg)
+		}
+	}
+}
+
+func TestTextLogger(t *testing.T) {
+	var buffer bytes.Buffer
+
+	logger := manager.NewTextLogger(&buffer, manager.InfoLevel)
+	logger.Debug("Request sent", "url", "http://example.com")
+	logger.Info("Download complete", "path", "/tmp/my file", "bytes", 1024)
+	logger.Warn("Retrying segment", "error")
+
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Want 2 lines, got %d: %q", len(lines), buffer.String())
+	}
+
+	var testCases = []struct {
+		line string
+		want string
+	}{
+		{line: lines[0], want: ` INFO Download complete path="/tmp/my file" bytes=1024`},
+		{line: lines[1], want: ` WARN Retrying segment error=`},
+	}
+
+	for _, testCase := range testCases {
+		if !strings.HasSuffix(testCase.line, testCase.want) {
+			t.Errorf("Want line ending with %q, got %q", testCase.want, testCase.line)
+		}
+	}
+}
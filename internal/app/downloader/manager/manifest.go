@@ -0,0 +1,197 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ManifestSaveInterval is the interval at which the resume manifest is saved while a download is running.
+const ManifestSaveInterval = 5 * time.Second
+
+// manifest is the resume state of a download saved next to the download file,
+// allowing a download to continue after the process is restarted.
+type manifest struct {
+	DownloadURL                   string            `json:"downloadUrl"`
+	Mirrors                       []string          `json:"mirrors,omitempty"`
+	MaxNrOfConcurrentConnection   int               `json:"maxNrOfConcurrentConnection"`
+	SaveDirectory                 string            `json:"saveDirectory"`
+	SaveFileName                  string            `json:"saveFileName"`
+	FileSize                      int64             `json:"fileSize"`
+	ETag                          string            `json:"eTag,omitempty"`
+	LastModified                  string            `json:"lastModified,omitempty"`
+	IsPauseAllowed                FlagState         `json:"isPauseAllowed"`
+	IsConcurrentConnectionAllowed FlagState         `json:"isConcurrentConnectionAllowed"`
+	PreallocateFile               bool              `json:"preallocateFile"`
+	ChecksumAlgorithm             HashAlgorithm     `json:"checksumAlgorithm,omitempty"`
+	Checksum                      string            `json:"checksum,omitempty"`
+	ChecksumFromServer            bool              `json:"checksumFromServer,omitempty"`
+	Segments                      []manifestSegment `json:"segments"`
+}
+
+// manifestSegment is the resume state of a segment of a download.
+type manifestSegment struct {
+	RangeStart     int64  `json:"rangeStart"`
+	RangeEnd       int64  `json:"rangeEnd"`
+	BytesCompleted int64  `json:"bytesCompleted"`
+	TempFilePath   string `json:"tempFilePath"`
+}
+
+// LoadDownload rebuilds a paused download from the resume manifest at the given path
+// with the configurations from the parameter input that are not saved in the manifest.
+// The returned download continues from the bytes already downloaded when Resume is called.
+func LoadDownload(path string, configurations ...ConfigOption) (*Download, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m manifest
+	if err = json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	if len(m.Segments) == 0 {
+		return nil, errors.New("resume manifest has no segments")
+	}
+
+	// The saved details are applied last so they are not overridden by the configurations
+	download, err := NewDownload(append(append([]ConfigOption(nil), configurations...),
+		DownloadURL(m.DownloadURL),
+		Mirrors(m.Mirrors...),
+		NrOfConcurrentDownload(m.MaxNrOfConcurrentConnection),
+		SaveDirectory(m.SaveDirectory),
+		SaveFileName(m.SaveFileName),
+		PreallocateFile(m.PreallocateFile))...)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = download.setFileSize(m.FileSize)
+	_ = download.setETag(m.ETag)
+	_ = download.setLastModified(m.LastModified)
+	_ = download.setIsPauseAllowed(m.IsPauseAllowed)
+	_ = download.setIsConcurrentConnectionAllowed(m.IsConcurrentConnectionAllowed)
+
+	if m.ChecksumAlgorithm != "" {
+		if err = download.SetChecksum(m.ChecksumAlgorithm, m.Checksum); err != nil {
+			return nil, err
+		}
+
+		download.isChecksumFromServer = m.ChecksumFromServer
+	}
+
+	// The mirrors were checked when the download was initialized
+	mirrors := make([]*mirror, len(download.mirrorURLs))
+	for i, mirrorURL := range download.mirrorURLs {
+		mirrors[i] = &mirror{url: mirrorURL}
+	}
+
+	download.setMirrorPool(mirrors)
+
+	for _, segment := range m.Segments {
+		downloader, err := download.newSegment(segment.RangeStart, segment.RangeEnd)
+		if err != nil {
+			return nil, err
+		}
+
+		downloader.appendToTempFileList(segment.TempFilePath)
+		_ = downloader.setBytesCompleted(segment.BytesCompleted)
+
+		// A temporary file holds the actual bytes downloaded, which may be ahead of the manifest
+		if err = downloader.syncBytesCompleted(); err != nil {
+			return nil, err
+		}
+
+		_ = download.addChild(downloader)
+	}
+
+	// The segments write to the preallocated file or to their own temporary file
+	if download.PreallocateFile() {
+		download.appendToTempFileList(m.Segments[0].TempFilePath)
+	} else {
+		for _, segment := range m.Segments {
+			download.appendToTempFileList(segment.TempFilePath)
+		}
+	}
+
+	// The bytes downloaded before the restart are hashed from the file once the download is complete
+	download.startChecksum()
+
+	// The loaded download is paused until it is resumed
+	_ = download.setIsDownloadInitialized(true)
+	_ = download.setIsDownloadStarted(true)
+
+	return download, nil
+}
+
+// ManifestPath returns the path of the resume manifest saved next to the download file.
+func (d *Download) ManifestPath() string {
+	return d.SaveFullPath() + "." + ManifestFileExtension
+}
+
+// saveManifest writes the current resume state of the download to the manifest file.
+// The manifest is written to a temporary file first and renamed,
+// so a process killed while saving never leaves a partially written manifest.
+func (d *Download) saveManifest() error {
+	m := manifest{
+		DownloadURL:                   d.DownloadURL(),
+		Mirrors:                       d.Mirrors(),
+		MaxNrOfConcurrentConnection:   d.MaxNrOfConcurrentConnection(),
+		SaveDirectory:                 d.SaveDirectory(),
+		SaveFileName:                  filepath.Base(d.SaveFullPath()),
+		FileSize:                      d.FileSize().Bytes(),
+		ETag:                          d.ETag(),
+		LastModified:                  d.LastModified(),
+		IsPauseAllowed:                d.IsPauseAllowed(),
+		IsConcurrentConnectionAllowed: d.IsConcurrentConnectionAllowed(),
+		PreallocateFile:               d.PreallocateFile(),
+		ChecksumAlgorithm:             d.ChecksumAlgorithm(),
+	}
+
+	if d.ChecksumAlgorithm() != "" {
+		m.Checksum = d.Checksum()
+		m.ChecksumFromServer = d.isChecksumFromServer
+	}
+
+	for _, child := range d.segments() {
+		rangeStart, rangeEnd := child.segmentRange()
+
+		m.Segments = append(m.Segments, manifestSegment{
+			RangeStart:     rangeStart,
+			RangeEnd:       rangeEnd,
+			BytesCompleted: child.BytesCompleted(),
+			TempFilePath:   child.tempFileList[0],
+		})
+	}
+
+	data, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	// The manifest is readable by the user only, as it holds the download URL and custom headers,
+	// and a temporary manifest left by an interrupted save is removed so it is created with that permission
+	tempManifestPath := d.ManifestPath() + ".temp"
+	if err = os.Remove(tempManifestPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err = ioutil.WriteFile(tempManifestPath, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tempManifestPath, d.ManifestPath())
+}
+
+// removeManifest deletes the manifest file once it is no longer needed.
+func (d *Download) removeManifest() error {
+	if err := os.Remove(d.ManifestPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package manager_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/util/file"
+)
+
+func TestLoadDownload(t *testing.T) {
+	const (
+		size                   = 512 * 1024
+		nrOfConcurrentDownload = 4
+	)
+
+	content := newTestContent(size)
+	g := newGate(32 * 1024)
+	server := newTestServer(content, g)
+	defer server.Close()
+
+	directory := newTestDirectory(t)
+
+	d, err := manager.NewDownload(
+		manager.DownloadURL(server.URL+"/download.bin"),
+		manager.NrOfConcurrentDownload(nrOfConcurrentDownload),
+		manager.SaveDirectory(directory),
+		manager.SaveFileName("download.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan error, 1)
+	go func() {
+		started <- d.Start()
+	}()
+
+	for i := 0; i < nrOfConcurrentDownload; i++ {
+		<-g.blocked
+	}
+
+	waitFor(t, func() bool {
+		return d.IsDownloadRunning() && d.BytesCompleted() == int64(nrOfConcurrentDownload*g.after)
+	})
+
+	// Pausing stands in for the process being stopped
+	if err = d.Pause(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = <-started; err != nil {
+		t.Fatal(err)
+	}
+
+	g.open()
+
+	// The manifest is readable by the user only
+	if runtime.GOOS != "windows" {
+		fileInfo, err := os.Stat(d.ManifestPath())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if fileInfo.Mode().Perm() != 0600 {
+			t.Errorf("Want manifest permission %v, got %v", os.FileMode(0600), fileInfo.Mode().Perm())
+		}
+	}
+
+	loaded, err := manager.LoadDownload(d.ManifestPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.BytesCompleted() != d.BytesCompleted() {
+		t.Errorf("Want %d bytes completed, got %d", d.BytesCompleted(), loaded.BytesCompleted())
+	}
+
+	if !loaded.IsDownloadPaused() {
+		t.Fatalf("Want loaded download paused")
+	}
+
+	if err = loaded.Resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	get, err := ioutil.ReadFile(filepath.Join(directory, "download.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(content, get) {
+		t.Errorf("Want %d bytes of content, got %d bytes that differ", len(content), len(get))
+	}
+
+	if file.IsFileExist(loaded.ManifestPath()) {
+		t.Errorf("Want manifest removed after completion")
+	}
+}
+
+func TestLoadDownloadMissingManifest(t *testing.T) {
+	directory := newTestDirectory(t)
+
+	if _, err := manager.LoadDownload(filepath.Join(directory, "missing."+manager.ManifestFileExtension)); err == nil {
+		t.Errorf("Want error loading a missing manifest")
+	}
+}
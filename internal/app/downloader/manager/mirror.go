@@ -0,0 +1,247 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// mirror is a URL serving the same file as the download URL.
+// Segments are spread across the mirrors and move to another mirror when theirs fails.
+type mirror struct {
+	url          *url.URL
+	originalURL  *url.URL // URL redirecting to the mirror URL, used once the mirror URL expires
+	eTag         string
+	lastModified string
+	failures     int
+	isDisabled   bool // The mirror failed with an error that is not transient
+}
+
+// newMirror returns a mirror downloading from the effective URL the original URL redirected to,
+// or from the original URL if it was not redirected.
+func newMirror(originalURL, effectiveURL *url.URL, eTag, lastModified string) *mirror {
+	m := &mirror{url: originalURL, eTag: eTag, lastModified: lastModified}
+
+	if effectiveURL != nil && effectiveURL.String() != originalURL.String() {
+		m.url = effectiveURL
+		m.originalURL = originalURL
+	}
+
+	return m
+}
+
+// Mirrors returns the mirror URLs serving the same file as the download URL.
+func (d *Download) Mirrors() []string {
+	mirrors := make([]string, len(d.mirrorURLs))
+	for i, mirrorURL := range d.mirrorURLs {
+		mirrors[i] = mirrorURL.String()
+	}
+
+	return mirrors
+}
+
+// SetMirrors sets the mirror URLs serving the same file as the download URL
+// and returns a non nil error if failed to set.
+// Mirrors are checked against the download URL by file size and entity tag in Initialize,
+// and inconsistent or unreachable mirrors are not used.
+func (d *Download) SetMirrors(mirrors []string) error {
+	mirrorURLs := make([]*url.URL, len(mirrors))
+
+	for i, mirror := range mirrors {
+		if len(mirror) == 0 {
+			return errors.New("mirror URL is empty")
+		}
+
+		parsedMirror, err := url.Parse(mirror)
+		if err != nil {
+			return err
+		}
+
+		mirrorURLs[i] = parsedMirror
+	}
+
+	d.mirrorURLs = mirrorURLs
+
+	return nil
+}
+
+// HealthyMirrors returns the URLs used by the segments of the download, including the download URL,
+// excluding mirrors that are inconsistent with the download URL or failed with an error that is not transient.
+func (d *Download) HealthyMirrors() []string {
+	d.mirrorMu.Lock()
+	defer d.mirrorMu.Unlock()
+
+	var mirrors []string
+	for _, m := range d.mirrors {
+		if !m.isDisabled {
+			mirrors = append(mirrors, m.url.String())
+		}
+	}
+
+	return mirrors
+}
+
+// setMirrorPool sets the download URL and the given mirrors as the mirrors used by the segments.
+func (d *Download) setMirrorPool(mirrors []*mirror) {
+	d.mirrorMu.Lock()
+	defer d.mirrorMu.Unlock()
+
+	d.mirrors = append([]*mirror{newMirror(d.downloadURL, d.effectiveURL, d.ETag(), d.LastModified())}, mirrors...)
+	d.nextMirrorIndex = 0
+}
+
+// probeMirrors probes all mirror URLs concurrently
+// and uses those serving the same file as the download URL.
+// The download URL must have been probed before.
+func (d *Download) probeMirrors() {
+	probed := make([]*mirror, len(d.mirrorURLs))
+
+	var wg sync.WaitGroup
+	for i, mirrorURL := range d.mirrorURLs {
+		wg.Add(1)
+
+		go func(i int, mirrorURL *url.URL) {
+			defer wg.Done()
+
+			m, err := d.probeMirror(mirrorURL)
+			if err != nil {
+				d.Logger().Warn("Mirror is not used", "mirror", mirrorURL, "error", err)
+			}
+
+			probed[i] = m
+		}(i, mirrorURL)
+	}
+
+	wg.Wait()
+
+	var mirrors []*mirror
+	for _, m := range probed {
+		if m != nil {
+			mirrors = append(mirrors, m)
+		}
+	}
+
+	d.setMirrorPool(mirrors)
+}
+
+// probeMirror probes a mirror URL and returns the mirror,
+// or a non nil error if it does not serve the same file as the download URL or does not support partial requests.
+func (d *Download) probeMirror(mirrorURL *url.URL) (*mirror, error) {
+	probe, err := NewDownload(
+		DownloadURL(mirrorURL.String()),
+		HTTPClient(d.HTTPClient()),
+		Log(d.Logger()),
+		MaxRedirects(d.MaxRedirects()),
+		CrossSchemeRedirect(d.IsCrossSchemeRedirectAllowed()))
+	if err != nil {
+		return nil, err
+	}
+
+	probe.header = d.header
+	probe.auth = d.auth
+
+	if err = probe.probe(); err != nil {
+		return nil, err
+	}
+
+	if probe.IsConcurrentConnectionAllowed() == notAllowed {
+		return nil, ErrRangeNotSupported
+	}
+
+	if probe.FileSize() != d.FileSize() {
+		return nil, fmt.Errorf("%w: mirror has %d bytes instead of %d bytes",
+			ErrSizeMismatch, probe.FileSize().Bytes(), d.FileSize().Bytes())
+	}
+
+	// A mirror sending a different entity tag than the download URL is treated as serving a different file
+	// and is not used
+	if probe.ETag() != "" && d.ETag() != "" && probe.ETag() != d.ETag() {
+		return nil, errors.New("entity tag " + probe.ETag() + " does not match " + d.ETag())
+	}
+
+	return newMirror(mirrorURL, probe.effectiveURL, probe.ETag(), probe.LastModified()), nil
+}
+
+// assignMirror sets the URL of a new segment to the next mirror in turn,
+// so the segments are spread across all healthy mirrors.
+func (d *Download) assignMirror(segment *Download) {
+	d.mirrorMu.Lock()
+	defer d.mirrorMu.Unlock()
+
+	for range d.mirrors {
+		m := d.mirrors[d.nextMirrorIndex%len(d.mirrors)]
+		d.nextMirrorIndex++
+
+		if !m.isDisabled {
+			segment.useMirror(m)
+
+			return
+		}
+	}
+}
+
+// switchMirror records the failure of the mirror used by a segment
+// and moves the segment to the healthy mirror with the fewest failures.
+// A mirror failing with an error that is not transient is not used anymore.
+// It returns a boolean indicating whether the segment moved to another mirror.
+func (d *Download) switchMirror(segment *Download, err error) bool {
+	d.mirrorMu.Lock()
+	defer d.mirrorMu.Unlock()
+
+	// File system errors are not caused by the mirror
+	var pathErr *os.PathError
+	current := segment.mirror
+	if current == nil || errors.As(err, &pathErr) {
+		return false
+	}
+
+	// An expired redirect target is replaced by the URL redirecting to it,
+	// so every later request follows the redirects to a valid target again.
+	// Other segments failing on the expired target afterwards continue from the URL replacing it.
+	if isRedirectTargetExpired(err) && (current.originalURL != nil || segment.downloadURL != current.url) {
+		if current.originalURL != nil {
+			d.Logger().Warn("Falling back to the URL redirecting to the expired URL",
+				"from", current.url, "to", current.originalURL, "error", err)
+
+			current.url = current.originalURL
+			current.originalURL = nil
+		}
+
+		segment.useMirror(current)
+
+		return true
+	}
+
+	current.failures++
+	if !isTransientError(err) {
+		current.isDisabled = true
+	}
+
+	var next *mirror
+	for _, m := range d.mirrors {
+		if m != current && !m.isDisabled && (next == nil || m.failures < next.failures) {
+			next = m
+		}
+	}
+
+	if next == nil {
+		return false
+	}
+
+	d.Logger().Warn("Moving segment to another mirror", "from", current.url, "to", next.url, "error", err)
+
+	segment.useMirror(next)
+
+	return true
+}
+
+// useMirror sets a segment to download from a mirror.
+// The validators of the mirror are checked to detect a change of the remote file.
+func (d *Download) useMirror(m *mirror) {
+	d.mirror = m
+	d.downloadURL = m.url
+	_ = d.setETag(m.eTag)
+	_ = d.setLastModified(m.lastModified)
+}
@@ -0,0 +1,114 @@
+package manager_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
+)
+
+// newMirrorServer serves content with range support, counting the range requests of segments.
+// Range requests fail with the status code if it is not 0.
+func newMirrorServer(content []byte, statusCode int, nrOfRangeRequests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.HasPrefix(r.Header.Get("Range"), "bytes=") {
+			atomic.AddInt32(nrOfRangeRequests, 1)
+
+			if statusCode != 0 {
+				w.WriteHeader(statusCode)
+				return
+			}
+		}
+
+		http.ServeContent(w, r, "download.bin", time.Time{}, bytes.NewReader(content))
+	}))
+}
+
+func TestMirrors(t *testing.T) {
+	const size = 512 * 1024
+
+	content := newTestContent(size)
+
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	var testCases = []struct {
+		name             string
+		statusCode       int
+		wantRangeRequest bool
+		wantHealthy      bool
+	}{
+		{name: "Healthy mirror", wantRangeRequest: true, wantHealthy: true},
+		{name: "Transient errors", statusCode: http.StatusServiceUnavailable, wantRangeRequest: true, wantHealthy: true},
+		{name: "Fatal error", statusCode: http.StatusForbidden, wantRangeRequest: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var nrOfPrimaryRequests, nrOfMirrorRequests, nrOfInconsistentRequests int32
+
+			primary := newMirrorServer(content, 0, &nrOfPrimaryRequests)
+			defer primary.Close()
+
+			mirror := newMirrorServer(content, testCase.statusCode, &nrOfMirrorRequests)
+			defer mirror.Close()
+
+			inconsistent := newMirrorServer(content[:size-1], 0, &nrOfInconsistentRequests)
+			defer inconsistent.Close()
+
+			directory := newTestDirectory(t)
+
+			d, err := manager.NewDownload(
+				manager.DownloadURL(primary.URL+"/download.bin"),
+				manager.Mirrors(mirror.URL+"/download.bin", inconsistent.URL+"/download.bin", unreachable.URL+"/download.bin"),
+				manager.NrOfConcurrentDownload(4),
+				manager.SaveDirectory(directory),
+				manager.SaveFileName("download.bin"),
+				manager.RetryBackoff(time.Millisecond, 10*time.Millisecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = d.Initialize(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Only the consistent mirror is used
+			if get := d.HealthyMirrors(); len(get) != 2 || get[1] != mirror.URL+"/download.bin" {
+				t.Fatalf("Want the download URL and the consistent mirror, got %v", get)
+			}
+
+			if err = d.Start(); err != nil {
+				t.Fatal(err)
+			}
+
+			get, err := ioutil.ReadFile(filepath.Join(directory, "download.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(content, get) {
+				t.Errorf("Want %d bytes of content, got %d bytes that differ", len(content), len(get))
+			}
+
+			if (atomic.LoadInt32(&nrOfMirrorRequests) > 0) != testCase.wantRangeRequest {
+				t.Errorf("Want range requests to the mirror %t, got %d", testCase.wantRangeRequest, nrOfMirrorRequests)
+			}
+
+			if atomic.LoadInt32(&nrOfInconsistentRequests) > 0 {
+				t.Errorf("Want no range requests to the inconsistent mirror, got %d", nrOfInconsistentRequests)
+			}
+
+			if get := len(d.HealthyMirrors()) == 2; get != testCase.wantHealthy {
+				t.Errorf("Want mirror healthy %t, got %t", testCase.wantHealthy, get)
+			}
+		})
+	}
+}
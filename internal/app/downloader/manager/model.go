@@ -3,12 +3,17 @@ package manager
 import (
 	"context"
 	"errors"
+	"hash"
 	"net/http"
 	"net/url"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/util/bandwidth"
 	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/util/file"
 )
 
@@ -16,8 +21,17 @@ const (
 	// MaxNrOfConcurrentConnectionAllowed is the maximum number of connection allowed.
 	MaxNrOfConcurrentConnectionAllowed = 64
 
+	// MinSegmentSplitSize is the minimum number of bytes a segment split for an idle connection can have.
+	MinSegmentSplitSize = 256 * 1024
+
+	// FallbackFileName is the default file name used when neither the server nor the download URL provides one.
+	FallbackFileName = "download"
+
 	// TempFileFileExtension is the file extension for temporary download file.
 	TempFileFileExtension = "qdm"
+
+	// ManifestFileExtension is the file extension for the resume manifest saved next to the download file.
+	ManifestFileExtension = "manifest." + TempFileFileExtension
 )
 
 // Download is a session of a download.
@@ -29,6 +43,54 @@ type Download struct {
 	saveFullPath                string
 	saveFileName                string
 	defaultFileName             string
+	preallocateFile             bool
+
+	// Mirrors serving the same file as the download URL
+	mirrorURLs      []*url.URL
+	mirrors         []*mirror // Download URL and consistent mirrors used by the segments
+	mirror          *mirror   // Mirror used by a segment
+	nextMirrorIndex int
+	mirrorMu        sync.Mutex
+
+	// Redirects of the download URL and the redirect policy
+	redirects                    []*url.URL
+	effectiveURL                 *url.URL
+	maxRedirects                 int
+	isCrossSchemeRedirectAllowed bool
+
+	// Checksum verification
+	checksumAlgorithm HashAlgorithm
+	checksum          []byte
+	checksumHash      hash.Hash
+	checksumOffset    int64 // Bytes hashed while downloading
+	checksumMu        sync.Mutex
+
+	isChecksumFromServer bool
+
+	// What happens when the remote file changes before the download is complete
+	changePolicy ChangePolicy
+
+	// HTTP client, headers and credentials shared by all segments
+	httpClient *http.Client
+	header     http.Header // Custom headers sent with every request
+	auth       *authentication
+
+	// Logger receiving the events of the download and all segments
+	logger Logger
+
+	// Speed limit shared by all segments
+	speedLimiter *bandwidth.Limiter
+
+	// Progress details
+	progressCallback func(Progress)
+	progressInterval time.Duration
+	progressTracker  progressTracker
+
+	// Retry details
+	maxRetries      int
+	retryMinBackoff time.Duration
+	retryMaxBackoff time.Duration
+	retryJitter     float64
 
 	// Flags
 	isPauseAllowed                FlagState
@@ -40,14 +102,23 @@ type Download struct {
 	isDownloadRunning     bool
 	isDownloadComplete    bool
 	isDownloadAborted     bool
+	isDownloadFailed      bool // The downloaded file is corrupted or outdated and cannot be resumed
+	isRunActive           bool // Start or Resume is running the download, guarded by operationMu
 
 	// Temporary files variables
 	tempFileNameAppender int
 	tempFileList         []string
 
+	// Segment details, the byte range is inclusive on both ends
+	rangeStart     int64
+	rangeEnd       int64
+	bytesCompleted int64
+
 	// Response
-	response *http.Response
-	fileSize file.Size
+	response     *http.Response
+	fileSize     file.Size
+	eTag         string
+	lastModified string
 
 	// Context
 	ctx       context.Context
@@ -56,6 +127,15 @@ type Download struct {
 	// Relation
 	parent   *Download
 	children []*Download
+
+	// Synchronization
+	operationMu sync.Mutex    // Serializes Start, Pause, Resume and Abort
+	ctxMu       sync.Mutex    // Guards the context while requests replace it and Abort cancels it
+	statusMu    sync.RWMutex  // Guards the download status flags
+	rangeMu     sync.Mutex    // Guards the byte range and writes of a segment while it is split
+	childrenMu  sync.RWMutex  // Guards the children while segments are split
+	splitMu     sync.Mutex    // Serializes splitting of segments
+	stopped     chan struct{} // Closed when the current run of the segments has stopped
 }
 
 // DownloadURL returns current download URL.
@@ -114,10 +194,9 @@ func (d *Download) SetSaveDirectory(directory string) error {
 	}
 
 	// Check if cleaned directory path exists
-	isFileExists := file.IsCleanedFileExist(directory)
-	if !isFileExists {
-		// Currently does not automatically create the missing directory
-		return errors.New("the given save directory does not exists")
+	// Currently does not automatically create the missing directory
+	if err := file.CheckDirectory(directory); err != nil {
+		return err
 	}
 
 	d.saveDirectory = directory
@@ -130,7 +209,7 @@ func (d *Download) SaveFileName() string {
 	return d.saveFileName
 }
 
-// setFileName is a helper method for setting file name
+// setFileName is a helper method validating a file name before it is set
 func (d *Download) setFileName(fileName string) error {
 	if len(fileName) == 0 {
 		return errors.New("file name cannot be empty")
@@ -150,8 +229,6 @@ func (d *Download) setFileName(fileName string) error {
 	//
 	// Make sure file name and path does not clash with other incomplete downloads in this application!
 
-	d.saveFileName = fileName
-
 	return nil
 }
 
@@ -186,6 +263,223 @@ func (d *Download) setDefaultFileName(fileName string) error {
 	return nil
 }
 
+// PreallocateFile returns a boolean indicating whether all segments write into a single preallocated file.
+func (d *Download) PreallocateFile() bool {
+	return d.preallocateFile
+}
+
+// SetPreallocateFile sets whether the download file is preallocated at its full size
+// and written by all segments at their own offsets,
+// instead of a temporary file per segment combined at the end.
+func (d *Download) SetPreallocateFile(preallocateFile bool) error {
+	d.preallocateFile = preallocateFile
+
+	return nil
+}
+
+// HTTPClient returns the HTTP client sending the requests of the download and its segments.
+func (d *Download) HTTPClient() *http.Client {
+	if d.httpClient == nil {
+		return http.DefaultClient
+	}
+
+	return d.httpClient
+}
+
+// SetHTTPClient sets the HTTP client sending the requests of the download and its segments
+// and returns a non nil error if failed to set.
+// Without a HTTP client set, http.DefaultClient is used.
+func (d *Download) SetHTTPClient(httpClient *http.Client) error {
+	if httpClient == nil {
+		return errors.New("HTTP client is nil")
+	}
+
+	d.httpClient = httpClient
+
+	return nil
+}
+
+// SetTransport sets the transport sending the requests of the download and its segments
+// with a new HTTP client and returns a non nil error if failed to set.
+func (d *Download) SetTransport(transport http.RoundTripper) error {
+	if transport == nil {
+		return errors.New("transport is nil")
+	}
+
+	return d.SetHTTPClient(&http.Client{Transport: transport})
+}
+
+// Header returns a copy of the custom headers sent with every request of the download and its segments.
+func (d *Download) Header() http.Header {
+	return d.header.Clone()
+}
+
+// SetHeader sets a custom header sent with every request of the download, its segments and mirrors,
+// replacing any value set before, and returns a non nil error if failed to set.
+// The Range and If-Range headers are set by the download and cannot be set.
+func (d *Download) SetHeader(key, value string) error {
+	if key == "" || strings.ContainsAny(key, " \t\r\n:") {
+		return errors.New("invalid header name: " + key)
+	}
+
+	if key = http.CanonicalHeaderKey(key); key == "Range" || key == "If-Range" {
+		return errors.New("header " + key + " is set by the download")
+	}
+
+	if strings.ContainsAny(value, "\r\n") {
+		return errors.New("invalid value for header " + key)
+	}
+
+	d.header.Set(key, value)
+
+	return nil
+}
+
+// UserAgent returns the User-Agent header sent with every request,
+// or an empty string if the default User-Agent of the HTTP client is sent.
+func (d *Download) UserAgent() string {
+	return d.header.Get("User-Agent")
+}
+
+// SetUserAgent sets the User-Agent header sent with every request and returns a non nil error if failed to set.
+func (d *Download) SetUserAgent(userAgent string) error {
+	if userAgent == "" {
+		return errors.New("user agent is empty")
+	}
+
+	return d.SetHeader("User-Agent", userAgent)
+}
+
+// Referer returns the Referer header sent with every request.
+func (d *Download) Referer() string {
+	return d.header.Get("Referer")
+}
+
+// SetReferer sets the Referer header sent with every request and returns a non nil error if failed to set.
+func (d *Download) SetReferer(referer string) error {
+	if _, err := url.ParseRequestURI(referer); err != nil {
+		return err
+	}
+
+	return d.SetHeader("Referer", referer)
+}
+
+// SpeedLimit returns the maximum bytes per second used by the download, 0 if unlimited.
+func (d *Download) SpeedLimit() int64 {
+	return d.speedLimiter.Limit()
+}
+
+// SetSpeedLimit sets the maximum bytes per second used by all segments of the download,
+// 0 for unlimited, and returns a non nil error if failed to set.
+// It can be changed while the download is running.
+func (d *Download) SetSpeedLimit(bytesPerSecond int64) error {
+	if bytesPerSecond < 0 {
+		return errors.New("speed limit cannot be negative")
+	}
+
+	d.speedLimiter.SetLimit(bytesPerSecond)
+
+	return nil
+}
+
+// ProgressInterval returns the interval at which the progress of a running download is reported.
+func (d *Download) ProgressInterval() time.Duration {
+	return d.progressInterval
+}
+
+// SetProgressInterval sets the interval at which the progress of a running download is reported
+// and returns a non nil error if failed to set.
+func (d *Download) SetProgressInterval(progressInterval time.Duration) error {
+	if progressInterval <= 0 {
+		return errors.New("progress interval must be positive")
+	}
+
+	d.progressInterval = progressInterval
+
+	return nil
+}
+
+// SetProgressCallback sets the function called with the progress of the download
+// at every progress interval while it is running, and once more whenever it stops.
+//
+// The callback is called from the goroutine running the download. It must return quickly
+// and must not call Pause, which waits for that goroutine to stop.
+func (d *Download) SetProgressCallback(progressCallback func(Progress)) error {
+	d.progressCallback = progressCallback
+
+	return nil
+}
+
+// MaxRetries returns the number of times a failed segment is retried without making progress.
+func (d *Download) MaxRetries() int {
+	return d.maxRetries
+}
+
+// SetMaxRetries sets the number of times a failed segment is retried without making progress
+// and returns a non nil error if failed to set.
+func (d *Download) SetMaxRetries(maxRetries int) error {
+	if maxRetries < 0 {
+		return errors.New("maximum number of retries cannot be negative")
+	}
+
+	d.maxRetries = maxRetries
+
+	return nil
+}
+
+// RetryMinBackoff returns the delay before the first retry of a failed segment.
+func (d *Download) RetryMinBackoff() time.Duration {
+	return d.retryMinBackoff
+}
+
+// RetryMaxBackoff returns the maximum delay between retries of a failed segment.
+func (d *Download) RetryMaxBackoff() time.Duration {
+	return d.retryMaxBackoff
+}
+
+// SetRetryBackoff sets the delay before the first retry of a failed segment,
+// which doubles on every retry up to the maximum delay,
+// and returns a non nil error if failed to set.
+func (d *Download) SetRetryBackoff(minBackoff, maxBackoff time.Duration) error {
+	if minBackoff <= 0 {
+		return errors.New("minimum retry backoff must be positive")
+	} else if maxBackoff < minBackoff {
+		return errors.New("maximum retry backoff is less than the minimum retry backoff")
+	}
+
+	d.retryMinBackoff = minBackoff
+	d.retryMaxBackoff = maxBackoff
+
+	return nil
+}
+
+// RetryJitter returns the fraction of the retry delay that is randomized.
+func (d *Download) RetryJitter() float64 {
+	return d.retryJitter
+}
+
+// SetRetryJitter sets the fraction of the retry delay that is randomized,
+// from 0 for no randomization to 1 for a delay anywhere between 0 and the full delay,
+// and returns a non nil error if failed to set.
+func (d *Download) SetRetryJitter(retryJitter float64) error {
+	if retryJitter < 0 || retryJitter > 1 {
+		return errors.New("retry jitter must be between 0 and 1")
+	}
+
+	d.retryJitter = retryJitter
+
+	return nil
+}
+
+// fileName returns the save file name if set, or the default file name provided by the server.
+func (d *Download) fileName() string {
+	if d.SaveFileName() != "" {
+		return d.SaveFileName()
+	}
+
+	return d.DefaultFileName()
+}
+
 // SaveFullPath returns the full path including directory and file name.
 func (d *Download) SaveFullPath() string {
 	return d.saveFullPath
@@ -195,13 +489,8 @@ func (d *Download) SaveFullPath() string {
 // and will attempt to combine current download save directory and save file name
 // and will return a non nil error if error occurred.
 func (d *Download) setSaveFullPath() error {
-	saveFileName := ""
-
-	if d.SaveFileName() != "" {
-		saveFileName = d.SaveFileName()
-	} else if d.DefaultFileName() != "" {
-		saveFileName = d.DefaultFileName()
-	} else {
+	saveFileName := d.fileName()
+	if saveFileName == "" {
 		return errors.New("save file name not set")
 	}
 
@@ -217,22 +506,57 @@ func (d *Download) setSaveFullPath() error {
 
 // FileSize returns the file size for the download.
 // If download details is not retrieved, file size should be 0.
+// If the server does not provide the file size, it is -1 until the download is complete.
 func (d *Download) FileSize() file.Size {
 	return d.fileSize
 }
 
+// isStream returns a boolean indicating whether the download is of unknown length,
+// which is streamed with a single request without a range.
+func (d *Download) isStream() bool {
+	return d.FileSize() < 0
+}
+
 func (d *Download) setFileSize(fileSize int64) error {
 	d.fileSize = file.Size(fileSize)
 
 	return nil
 }
 
+// ETag returns the entity tag of the remote file provided by the server.
+func (d *Download) ETag() string {
+	return d.eTag
+}
+
+func (d *Download) setETag(eTag string) error {
+	d.eTag = eTag
+
+	return nil
+}
+
+// LastModified returns the last modified date of the remote file provided by the server.
+func (d *Download) LastModified() string {
+	return d.lastModified
+}
+
+func (d *Download) setLastModified(lastModified string) error {
+	d.lastModified = lastModified
+
+	return nil
+}
+
 // IsPauseAllowed returns a state indicating if pausing the download is supported.
 func (d *Download) IsPauseAllowed() FlagState {
+	d.statusMu.RLock()
+	defer d.statusMu.RUnlock()
+
 	return d.isPauseAllowed
 }
 
 func (d *Download) setIsPauseAllowed(isPauseAllowed FlagState) error {
+	d.statusMu.Lock()
+	defer d.statusMu.Unlock()
+
 	d.isPauseAllowed = isPauseAllowed
 
 	return nil
@@ -240,10 +564,16 @@ func (d *Download) setIsPauseAllowed(isPauseAllowed FlagState) error {
 
 // IsConcurrentConnectionAllowed returns a state indicating if concurrent connection is supported.
 func (d *Download) IsConcurrentConnectionAllowed() FlagState {
+	d.statusMu.RLock()
+	defer d.statusMu.RUnlock()
+
 	return d.isConcurrentConnectionAllowed
 }
 
 func (d *Download) setIsConcurrentConnectionAllowed(isConcurrentConnectionAllowed FlagState) error {
+	d.statusMu.Lock()
+	defer d.statusMu.Unlock()
+
 	d.isConcurrentConnectionAllowed = isConcurrentConnectionAllowed
 
 	return nil
@@ -262,10 +592,16 @@ func (d *Download) setIsDownloadInitialized(isDownloadInitialized bool) error {
 
 // IsDownloadStarted returns a boolean indicating if the download has been started before.
 func (d *Download) IsDownloadStarted() bool {
+	d.statusMu.RLock()
+	defer d.statusMu.RUnlock()
+
 	return d.isDownloadStarted
 }
 
 func (d *Download) setIsDownloadStarted(isDownloadStarted bool) error {
+	d.statusMu.Lock()
+	defer d.statusMu.Unlock()
+
 	d.isDownloadStarted = isDownloadStarted
 
 	return nil
@@ -273,18 +609,38 @@ func (d *Download) setIsDownloadStarted(isDownloadStarted bool) error {
 
 // IsDownloadRunning returns a boolean indicating whether the download is currently running.
 func (d *Download) IsDownloadRunning() bool {
+	d.statusMu.RLock()
+	defer d.statusMu.RUnlock()
+
 	return d.isDownloadRunning
 }
 
 func (d *Download) setIsDownloadRunning(isDownloadRunning bool) error {
+	d.statusMu.Lock()
+	defer d.statusMu.Unlock()
+
 	d.isDownloadRunning = isDownloadRunning
 
 	return nil
 }
 
+// stopRunning flags the download as not running
+// and returns a boolean indicating whether it was running before.
+// Only one of the concurrent callers stopping a running download gets true.
+func (d *Download) stopRunning() bool {
+	d.statusMu.Lock()
+	defer d.statusMu.Unlock()
+
+	wasRunning := d.isDownloadRunning
+	d.isDownloadRunning = false
+
+	return wasRunning
+}
+
 // IsDownloadPaused returns a boolean indicating whether the download is paused.
 func (d *Download) IsDownloadPaused() bool {
-	if !d.IsDownloadRunning() && !d.IsDownloadComplete() && !d.isDownloadAborted {
+	if d.IsDownloadStarted() && !d.IsDownloadRunning() && !d.IsDownloadComplete() &&
+		!d.IsDownloadAborted() && !d.IsDownloadFailed() {
 		return true
 	}
 
@@ -293,26 +649,121 @@ func (d *Download) IsDownloadPaused() bool {
 
 // IsDownloadComplete returns a boolean indicating whether the download has been completed.
 func (d *Download) IsDownloadComplete() bool {
+	d.statusMu.RLock()
+	defer d.statusMu.RUnlock()
+
 	return d.isDownloadComplete
 }
 
 // IsDownloadAborted returns a boolean indicating whether the download has been completed.
 func (d *Download) IsDownloadAborted() bool {
+	d.statusMu.RLock()
+	defer d.statusMu.RUnlock()
+
 	return d.isDownloadAborted
 }
 
 func (d *Download) setIsDownloadAborted(isDownloadAborted bool) error {
+	d.statusMu.Lock()
+	defer d.statusMu.Unlock()
+
 	d.isDownloadAborted = isDownloadAborted
 
 	return nil
 }
 
+// IsDownloadFailed returns a boolean indicating whether the downloaded file failed verification
+// or the remote file changed before the download is complete.
+func (d *Download) IsDownloadFailed() bool {
+	d.statusMu.RLock()
+	defer d.statusMu.RUnlock()
+
+	return d.isDownloadFailed
+}
+
+func (d *Download) setIsDownloadFailed(isDownloadFailed bool) error {
+	d.statusMu.Lock()
+	defer d.statusMu.Unlock()
+
+	d.isDownloadFailed = isDownloadFailed
+
+	return nil
+}
+
 func (d *Download) setIsDownloadComplete(isDownloadComplete bool) error {
+	d.statusMu.Lock()
+	defer d.statusMu.Unlock()
+
 	d.isDownloadComplete = isDownloadComplete
 
 	return nil
 }
 
+// BytesCompleted returns the number of bytes downloaded so far.
+// For a download split into segments, it is the sum of the bytes completed by all segments.
+func (d *Download) BytesCompleted() int64 {
+	segments := d.segments()
+	if len(segments) == 0 {
+		return atomic.LoadInt64(&d.bytesCompleted)
+	}
+
+	var bytesCompleted int64
+	for _, segment := range segments {
+		bytesCompleted += segment.BytesCompleted()
+	}
+
+	return bytesCompleted
+}
+
+func (d *Download) setBytesCompleted(bytesCompleted int64) error {
+	atomic.StoreInt64(&d.bytesCompleted, bytesCompleted)
+
+	return nil
+}
+
+func (d *Download) addBytesCompleted(bytes int64) {
+	atomic.AddInt64(&d.bytesCompleted, bytes)
+}
+
+// setRange sets the inclusive byte range of the file to be downloaded by a segment.
+func (d *Download) setRange(rangeStart, rangeEnd int64) error {
+	if rangeStart < 0 || rangeEnd < rangeStart {
+		return errors.New("invalid byte range " +
+			strconv.FormatInt(rangeStart, 10) +
+			"-" +
+			strconv.FormatInt(rangeEnd, 10))
+	}
+
+	d.rangeMu.Lock()
+	defer d.rangeMu.Unlock()
+
+	d.rangeStart = rangeStart
+	d.rangeEnd = rangeEnd
+
+	return nil
+}
+
+// segmentRange returns the inclusive byte range of a segment.
+// The end of the range moves back when the segment is split.
+func (d *Download) segmentRange() (int64, int64) {
+	d.rangeMu.Lock()
+	defer d.rangeMu.Unlock()
+
+	return d.rangeStart, d.rangeEnd
+}
+
+// remainingBytes returns the number of bytes in the byte range of a segment not downloaded yet.
+func (d *Download) remainingBytes() int64 {
+	rangeStart, rangeEnd := d.segmentRange()
+
+	return rangeEnd - rangeStart + 1 - d.BytesCompleted()
+}
+
+// isSegmentComplete returns a boolean indicating whether all bytes in the segment range have been downloaded.
+func (d *Download) isSegmentComplete() bool {
+	return d.remainingBytes() <= 0
+}
+
 func (d *Download) setTempFileNameAppender(fileAppender int) error {
 	d.tempFileNameAppender = fileAppender
 
@@ -325,16 +776,38 @@ func (d *Download) setTempFileList(tempFileList []string) error {
 	return nil
 }
 
-func (d *Download) setCtx(ctx context.Context) error {
-	d.ctx = ctx
+// newCtx sets up a new context derived from the parent context for stopping the download.
+// It returns ErrAborted instead once the download is aborted,
+// so a request cannot be sent with a context created after Abort cancelled the previous one.
+func (d *Download) newCtx(parentCtx context.Context) (context.Context, error) {
+	d.ctxMu.Lock()
+	defer d.ctxMu.Unlock()
 
-	return nil
+	if d.IsDownloadAborted() {
+		return nil, ErrAborted
+	}
+
+	d.ctx, d.ctxCancel = context.WithCancel(parentCtx)
+
+	return d.ctx, nil
 }
 
-func (d *Download) setCtxCancel(ctxCancel func()) error {
-	d.ctxCancel = ctxCancel
+// currentCtx returns the context of the current run or request of the download, or nil if there is none yet.
+func (d *Download) currentCtx() context.Context {
+	d.ctxMu.Lock()
+	defer d.ctxMu.Unlock()
 
-	return nil
+	return d.ctx
+}
+
+// cancelCtx cancels the context of the current run or request of the download, if any.
+func (d *Download) cancelCtx() {
+	d.ctxMu.Lock()
+	defer d.ctxMu.Unlock()
+
+	if d.ctxCancel != nil {
+		d.ctxCancel()
+	}
 }
 
 func (d *Download) setResponse(response *http.Response) error {
@@ -360,12 +833,24 @@ func (d *Download) setChildren(children []*Download) error {
 // addChild adds a child to the caller parent instance
 // and update the child parent to the caller instance.
 func (d *Download) addChild(child *Download) error {
+	d.childrenMu.Lock()
+	defer d.childrenMu.Unlock()
+
 	_ = child.setParent(d)
 	_ = d.setChildren(append(d.children, child))
 
 	return nil
 }
 
+// segments returns a copy of the children,
+// which are the segments of a download split into byte ranges.
+func (d *Download) segments() []*Download {
+	d.childrenMu.RLock()
+	defer d.childrenMu.RUnlock()
+
+	return append([]*Download(nil), d.children...)
+}
+
 func (d *Download) String() string {
 	sb := strings.Builder{}
 
@@ -373,6 +858,10 @@ func (d *Download) String() string {
 	sb.WriteString(d.DownloadURL())
 	sb.WriteString("\n")
 
+	sb.WriteString("Effective URL: ")
+	sb.WriteString(d.EffectiveURL())
+	sb.WriteString("\n")
+
 	sb.WriteString("Number of concurrent download: ")
 	sb.WriteString(strconv.Itoa(d.MaxNrOfConcurrentConnection()))
 	sb.WriteString("\n")
@@ -413,5 +902,9 @@ func (d *Download) String() string {
 	sb.WriteString(strconv.FormatBool(d.IsDownloadComplete()))
 	sb.WriteString("\n")
 
+	sb.WriteString("Is download failed:")
+	sb.WriteString(strconv.FormatBool(d.IsDownloadFailed()))
+	sb.WriteString("\n")
+
 	return sb.String()
 }
@@ -1,23 +1,22 @@
 package manager
 
-import (
-	"errors"
-)
-
 // Initialize initialize the new download by sending a request to the download URL
 // and updating the download fields value by processing the received header.
 func (d *Download) Initialize() error {
-	if d.isDownloadRunning {
-		return errors.New("download is currently running")
+	if d.IsDownloadRunning() {
+		return ErrDownloadRunning
 	}
 
-	// Send a HTTP request to get the request header
-	if err := d.sendHTTPRequest(nil); err != nil {
+	// Probe the download URL to get the response header
+	if err := d.probe(); err != nil {
 		return err
 	}
 
-	// Process the received request header
-	if err := d.processRequestHeader(); err != nil {
+	// Spread the segments across the mirrors serving the same file
+	d.probeMirrors()
+
+	// Update save full path with the default file name if save file name is not set
+	if err := d.setSaveFullPath(); err != nil {
 		return err
 	}
 
@@ -26,53 +25,131 @@ func (d *Download) Initialize() error {
 }
 
 // Start starts the download first time.
+// If a file exists at the save path, the download is saved under a numbered file name, such as "report (1).pdf".
+// It blocks until the download is completed, paused or aborted.
 func (d *Download) Start() error {
 	// Test
 	// _ = d.setIsConcurrentConnectionAllowed(notAllowed)
 
+	d.operationMu.Lock()
+
 	// Block if download has started before
 	if d.IsDownloadStarted() {
-		return errors.New("download has already started before. Did you mean resume download ")
+		d.operationMu.Unlock()
+		return ErrAlreadyStarted
 	}
 
 	// Flag the download has started
 	_ = d.setIsDownloadStarted(true)
+	d.isRunActive = true
+
+	d.operationMu.Unlock()
 
 	// Create a place holder file
-	d.createPlaceHolderFile()
+	if err := d.createPlaceHolderFile(); err != nil {
+		d.fail(err)
+		return d.endRun(err)
+	}
 
-	return d.startDownload()
+	return d.endRun(d.restartIfNeeded(d.startDownload()))
 }
 
 // Pause will pause the current download if it is currently running.
+// All in-flight range requests are cancelled
+// and the bytes held by each temporary file are recorded for Resume.
 func (d *Download) Pause() error {
-	_ = d.setIsDownloadRunning(false)
+	d.operationMu.Lock()
+	defer d.operationMu.Unlock()
+
+	if d.IsPauseAllowed() == notAllowed {
+		return ErrRangeNotSupported
+	}
+
+	if !d.stopRunning() {
+		return ErrNotRunning
+	}
+
+	// Stop all segments and wait for them to record their progress
+	d.cancelCtx()
+	<-d.stopped
 
 	return nil
 }
 
 // Resume will continue the current download if it is paused.
+// Range requests are reissued from the bytes already downloaded by each segment.
+// If the remote file changed since the download started, it restarts or fails depending on the change policy.
+// It blocks until the download is completed, paused or aborted.
 func (d *Download) Resume() error {
-	_ = d.setIsDownloadRunning(true)
+	d.operationMu.Lock()
 
-	return nil
+	// A download restarting after its run stopped is not paused
+	if !d.IsDownloadPaused() || d.isRunActive {
+		d.operationMu.Unlock()
+		return ErrNotPaused
+	}
+
+	err := d.beginRun()
+	if err == nil {
+		d.isRunActive = true
+	}
+
+	d.operationMu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return d.endRun(d.restartIfNeeded(d.runSegments()))
+}
+
+// endRun flags that Start or Resume stopped running the download and returns the error of the run.
+// The files of a download aborted while it was running are discarded here, once nothing writes to them.
+func (d *Download) endRun(err error) error {
+	d.operationMu.Lock()
+	defer d.operationMu.Unlock()
+
+	d.isRunActive = false
+
+	if d.IsDownloadAborted() && !d.IsDownloadComplete() {
+		d.discardFiles()
+	}
+
+	return err
 }
 
 // Abort will cancel the current download.
+// The temporary files, the resume manifest and the placeholder file of a started download are deleted,
+// so an aborted download cannot be resumed by LoadDownload. A complete download is kept.
 func (d *Download) Abort() {
-	// Abort all the children
-	for _, v := range d.children {
-		v.ctxCancel()
-	}
+	d.operationMu.Lock()
+	defer d.operationMu.Unlock()
 
-	// Abort the caller download instance
-	d.ctxCancel()
 	_ = d.setIsDownloadAborted(true)
+	_ = d.stopRunning()
+
+	// Abort the caller download instance
+	// Cancelling the context also cancels the requests of all the children
+	d.cancelCtx()
+
+	// A running download discards its files once its run stopped
+	if !d.isRunActive && d.IsDownloadStarted() && !d.IsDownloadComplete() {
+		d.discardFiles()
+	}
+}
+
+// fail will update the download status to failed because of the error.
+func (d *Download) fail(err error) {
+	_ = d.setIsDownloadFailed(true)
 	_ = d.setIsDownloadRunning(false)
+
+	d.Logger().Error("Download failed", "url", d.DownloadURL(), "error", err)
 }
 
 // complete will update the download status to complete.
 func (d *Download) complete() {
 	_ = d.setIsDownloadComplete(true)
 	_ = d.setIsDownloadRunning(false)
+
+	d.Logger().Info("Download complete", "path", d.SaveFullPath(), "bytes", d.FileSize().Bytes())
 }
@@ -0,0 +1,668 @@
+package manager_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/util/file"
+)
+
+// gatedWriter blocks a range response after writing a number of bytes until the gate is opened.
+type gatedWriter struct {
+	http.ResponseWriter
+	request *http.Request
+	gate    *gate
+	written int
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	if w.written >= w.gate.after {
+		w.gate.wait(w.request)
+	}
+
+	n, err := w.ResponseWriter.Write(p)
+	w.written += n
+
+	// Make sure the client receives the bytes before blocking
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	return n, err
+}
+
+// gate holds range responses until it is opened.
+type gate struct {
+	after   int
+	mu      sync.Mutex
+	opened  chan struct{}
+	blocked chan struct{}
+}
+
+func newGate(after int) *gate {
+	return &gate{
+		after:   after,
+		opened:  make(chan struct{}),
+		blocked: make(chan struct{}, manager.MaxNrOfConcurrentConnectionAllowed),
+	}
+}
+
+func (g *gate) wait(r *http.Request) {
+	select {
+	case <-g.opened:
+		return
+	default:
+	}
+
+	g.blocked <- struct{}{}
+
+	select {
+	case <-g.opened:
+	case <-r.Context().Done():
+	}
+}
+
+func (g *gate) open() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	select {
+	case <-g.opened:
+	default:
+		close(g.opened)
+	}
+}
+
+// newTestServer serves content with range support.
+// Range responses are held by the gate if it is not nil.
+func newTestServer(content []byte, g *gate) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if g != nil && r.Header.Get("Range") != "" {
+			w = &gatedWriter{ResponseWriter: w, request: r, gate: g}
+		}
+
+		http.ServeContent(w, r, "download.bin", time.Time{}, bytes.NewReader(content))
+	}))
+}
+
+func newTestContent(size int) []byte {
+	content := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(content)
+
+	return content
+}
+
+func newTestDirectory(t *testing.T) string {
+	directory, err := ioutil.TempDir("", "qdm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = os.RemoveAll(directory)
+	})
+
+	return directory
+}
+
+// waitFor waits until the condition is true or fails the test after a timeout.
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for !condition() {
+		select {
+		case <-timeout:
+			t.Fatal("Timed out waiting for condition")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestStart(t *testing.T) {
+	var testCases = []struct {
+		name                   string
+		size                   int
+		nrOfConcurrentDownload int
+		preallocateFile        bool
+	}{
+		{name: "Single connection", size: 100 * 1024, nrOfConcurrentDownload: 1},
+		{name: "Concurrent connections", size: 100 * 1024, nrOfConcurrentDownload: 8},
+		{name: "More connections than bytes", size: 3, nrOfConcurrentDownload: 8},
+		{name: "Preallocated file", size: 100 * 1024, nrOfConcurrentDownload: 8, preallocateFile: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			content := newTestContent(testCase.size)
+			server := newTestServer(content, nil)
+			defer server.Close()
+
+			directory := newTestDirectory(t)
+
+			d, err := manager.NewDownload(
+				manager.DownloadURL(server.URL+"/download.bin"),
+				manager.NrOfConcurrentDownload(testCase.nrOfConcurrentDownload),
+				manager.SaveDirectory(directory),
+				manager.SaveFileName("download.bin"),
+				manager.PreallocateFile(testCase.preallocateFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = d.Initialize(); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = d.Start(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !d.IsDownloadComplete() {
+				t.Errorf("Want download complete")
+			}
+
+			get, err := ioutil.ReadFile(filepath.Join(directory, "download.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(content, get) {
+				t.Errorf("Want %d bytes of content, got %d bytes that differ", len(content), len(get))
+			}
+		})
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	const (
+		size                   = 512 * 1024
+		nrOfConcurrentDownload = 4
+	)
+
+	for _, preallocateFile := range []bool{false, true} {
+		t.Run("Preallocate file "+strconv.FormatBool(preallocateFile), func(t *testing.T) {
+			testPauseResume(t, size, nrOfConcurrentDownload, preallocateFile)
+		})
+	}
+}
+
+func testPauseResume(t *testing.T, size, nrOfConcurrentDownload int, preallocateFile bool) {
+	content := newTestContent(size)
+	g := newGate(32 * 1024)
+	server := newTestServer(content, g)
+	defer server.Close()
+
+	directory := newTestDirectory(t)
+
+	d, err := manager.NewDownload(
+		manager.DownloadURL(server.URL+"/download.bin"),
+		manager.NrOfConcurrentDownload(nrOfConcurrentDownload),
+		manager.SaveDirectory(directory),
+		manager.SaveFileName("download.bin"),
+		manager.PreallocateFile(preallocateFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan error, 1)
+	go func() {
+		started <- d.Start()
+	}()
+
+	// Wait for every connection to be held by the server
+	for i := 0; i < nrOfConcurrentDownload; i++ {
+		<-g.blocked
+	}
+
+	// Wait for the bytes sent before the gate to be written
+	waitFor(t, func() bool {
+		return d.IsDownloadRunning() && d.BytesCompleted() == int64(nrOfConcurrentDownload*g.after)
+	})
+
+	if err = d.Pause(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = <-started; err != nil {
+		t.Fatal(err)
+	}
+
+	if !d.IsDownloadPaused() {
+		t.Fatalf("Want download paused")
+	}
+
+	bytesCompleted := d.BytesCompleted()
+	if bytesCompleted <= 0 || bytesCompleted >= int64(size) {
+		t.Fatalf("Want bytes completed between 0 and %d, got %d", size, bytesCompleted)
+	}
+
+	if err = d.Pause(); err == nil {
+		t.Errorf("Want error pausing a paused download")
+	}
+
+	g.open()
+
+	if err = d.Resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !d.IsDownloadComplete() {
+		t.Fatalf("Want download complete")
+	}
+
+	get, err := ioutil.ReadFile(filepath.Join(directory, "download.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(content, get) {
+		t.Errorf("Want %d bytes of content, got %d bytes that differ", len(content), len(get))
+	}
+}
+
+func TestAbort(t *testing.T) {
+	const (
+		size                   = 512 * 1024
+		nrOfConcurrentDownload = 4
+	)
+
+	for _, isPaused := range []bool{false, true} {
+		t.Run("Paused "+strconv.FormatBool(isPaused), func(t *testing.T) {
+			g := newGate(32 * 1024)
+			server := newTestServer(newTestContent(size), g)
+			defer server.Close()
+			defer g.open()
+
+			directory := newTestDirectory(t)
+
+			d, err := manager.NewDownload(
+				manager.DownloadURL(server.URL+"/download.bin"),
+				manager.NrOfConcurrentDownload(nrOfConcurrentDownload),
+				manager.SaveDirectory(directory),
+				manager.SaveFileName("download.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = d.Initialize(); err != nil {
+				t.Fatal(err)
+			}
+
+			started := make(chan error, 1)
+			go func() {
+				started <- d.Start()
+			}()
+
+			for i := 0; i < nrOfConcurrentDownload; i++ {
+				<-g.blocked
+			}
+
+			waitFor(t, func() bool {
+				return d.IsDownloadRunning() && d.BytesCompleted() == int64(nrOfConcurrentDownload*g.after)
+			})
+
+			var wantErr error = manager.ErrAborted
+			if isPaused {
+				if err = d.Pause(); err != nil {
+					t.Fatal(err)
+				}
+
+				// The manifest of the paused download is saved before it is aborted
+				if !file.IsFileExist(d.ManifestPath()) {
+					t.Fatalf("Want manifest saved to %q", d.ManifestPath())
+				}
+
+				wantErr = nil
+			}
+
+			d.Abort()
+
+			if err = <-started; !errors.Is(err, wantErr) {
+				t.Fatalf("Want error %v, got %v", wantErr, err)
+			}
+
+			// The temporary files, the manifest and the placeholder file are deleted
+			files, err := ioutil.ReadDir(directory)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, f := range files {
+				t.Errorf("Want no file left, got %s", f.Name())
+			}
+
+			if _, err = manager.LoadDownload(d.ManifestPath()); err == nil {
+				t.Error("Want error loading the manifest of an aborted download")
+			}
+		})
+	}
+}
+
+func TestInitialize(t *testing.T) {
+	const size = 64 * 1024
+
+	content := newTestContent(size)
+
+	var testCases = []struct {
+		name                              string
+		handler                           http.HandlerFunc
+		wantIsConcurrentConnectionAllowed string
+	}{
+		{
+			name: "Range support",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.ServeContent(w, r, "download.bin", time.Time{}, bytes.NewReader(content))
+			},
+			wantIsConcurrentConnectionAllowed: "allowed",
+		},
+		{
+			name: "HEAD not supported",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodHead {
+					w.WriteHeader(http.StatusMethodNotAllowed)
+					return
+				}
+
+				http.ServeContent(w, r, "download.bin", time.Time{}, bytes.NewReader(content))
+			},
+			wantIsConcurrentConnectionAllowed: "allowed",
+		},
+		{
+			name: "Range ignored",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", strconv.Itoa(size))
+				_, _ = w.Write(content)
+			},
+			wantIsConcurrentConnectionAllowed: "not allowed",
+		},
+		{
+			name: "Range not accepted",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Accept-Ranges", "none")
+				w.Header().Set("Content-Length", strconv.Itoa(size))
+				_, _ = w.Write(content)
+			},
+			wantIsConcurrentConnectionAllowed: "not allowed",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var nrOfFullRequests int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet && r.Header.Get("Range") == "" {
+					atomic.AddInt32(&nrOfFullRequests, 1)
+				}
+
+				testCase.handler(w, r)
+			}))
+			defer server.Close()
+
+			d, err := manager.NewDownload(
+				manager.DownloadURL(server.URL+"/download.bin"),
+				manager.NrOfConcurrentDownload(4),
+				manager.SaveDirectory(newTestDirectory(t)),
+				manager.SaveFileName("download.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = d.Initialize(); err != nil {
+				t.Fatal(err)
+			}
+
+			if d.FileSize().Bytes() != size {
+				t.Errorf("Want file size %d, got %d", size, d.FileSize().Bytes())
+			}
+
+			if get := d.IsConcurrentConnectionAllowed().String(); get != testCase.wantIsConcurrentConnectionAllowed {
+				t.Errorf("Want concurrent connection %s, got %s", testCase.wantIsConcurrentConnectionAllowed, get)
+			}
+
+			if get := atomic.LoadInt32(&nrOfFullRequests); get != 0 {
+				t.Errorf("Want no request for the whole file, got %d", get)
+			}
+		})
+	}
+}
+
+func TestDefaultFileName(t *testing.T) {
+	var testCases = []struct {
+		name               string
+		path               string
+		contentDisposition string
+		want               string
+	}{
+		{name: "Quoted", path: "/file", contentDisposition: `attachment; filename="report.pdf"`, want: "report.pdf"},
+		{name: "Unquoted with spaces", path: "/file", contentDisposition: `attachment; filename=my report.pdf`, want: "my report.pdf"},
+		{name: "UTF-8 encoded", path: "/file", contentDisposition: `attachment; filename*=UTF-8''%E2%82%AC%20rates.txt`, want: "€ rates.txt"},
+		{name: "ISO-8859-1 encoded", path: "/file", contentDisposition: `attachment; filename*=iso-8859-1''%A3%20rates.txt`, want: "£ rates.txt"},
+		{name: "Encoded preferred", path: "/file", contentDisposition: `attachment; filename="rates.txt"; filename*=UTF-8''%E2%82%AC%20rates.txt`, want: "€ rates.txt"},
+		{name: "Directory stripped", path: "/file", contentDisposition: `attachment; filename="../../etc/passwd"`, want: "passwd"},
+		{name: "Hidden file", path: "/file", contentDisposition: `attachment; filename=".bashrc"`, want: "bashrc"},
+		{name: "Hidden URL path", path: "/config/.env", want: "env"},
+		{name: "Dots only", path: "/file", contentDisposition: `attachment; filename="..."`, want: "file"},
+		{name: "URL path", path: "/files/archive%20v1.zip", want: "archive v1.zip"},
+		{name: "No name", path: "/", want: manager.FallbackFileName},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if testCase.contentDisposition != "" {
+					w.Header().Set("Content-Disposition", testCase.contentDisposition)
+				}
+
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(newTestContent(1024)))
+			}))
+			defer server.Close()
+
+			directory := newTestDirectory(t)
+
+			d, err := manager.NewDownload(
+				manager.DownloadURL(server.URL+testCase.path),
+				manager.SaveDirectory(directory))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = d.Initialize(); err != nil {
+				t.Fatal(err)
+			}
+
+			if d.DefaultFileName() != testCase.want {
+				t.Errorf("Want default file name %q, got %q", testCase.want, d.DefaultFileName())
+			}
+
+			if want := filepath.Join(directory, testCase.want); d.SaveFullPath() != want {
+				t.Errorf("Want save full path %q, got %q", want, d.SaveFullPath())
+			}
+
+			if err = d.Start(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !file.IsFileExist(d.SaveFullPath()) {
+				t.Errorf("Want file saved to %q", d.SaveFullPath())
+			}
+		})
+	}
+}
+
+func TestExistingFile(t *testing.T) {
+	content := newTestContent(64 * 1024)
+	server := newTestServer(content, nil)
+	defer server.Close()
+
+	directory := newTestDirectory(t)
+	existing := []byte("existing")
+
+	for _, fileName := range []string{"download.bin", "download (1).bin"} {
+		if err := ioutil.WriteFile(filepath.Join(directory, fileName), existing, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d, err := manager.NewDownload(
+		manager.DownloadURL(server.URL+"/download.bin"),
+		manager.SaveDirectory(directory))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The existing files are kept and the download is saved under the next free numbered name
+	if want := filepath.Join(directory, "download (2).bin"); d.SaveFullPath() != want {
+		t.Errorf("Want save full path %q, got %q", want, d.SaveFullPath())
+	}
+
+	var testCases = []struct {
+		name     string
+		fileName string
+		want     []byte
+	}{
+		{name: "Existing file", fileName: "download.bin", want: existing},
+		{name: "Existing numbered file", fileName: "download (1).bin", want: existing},
+		{name: "Download", fileName: "download (2).bin", want: content},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			get, err := ioutil.ReadFile(filepath.Join(directory, testCase.fileName))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(testCase.want, get) {
+				t.Errorf("Want %d bytes of content, got %d bytes that differ", len(testCase.want), len(get))
+			}
+		})
+	}
+}
+
+func TestOperationErrors(t *testing.T) {
+	content := newTestContent(64 * 1024)
+
+	// The server without range support sends the whole file for every request
+	noRangeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		_, _ = w.Write(content)
+	}))
+	defer noRangeServer.Close()
+
+	server := newTestServer(content, nil)
+	defer server.Close()
+
+	newDownload := func(t *testing.T, url string) *manager.Download {
+		d, err := manager.NewDownload(
+			manager.DownloadURL(url),
+			manager.SaveDirectory(newTestDirectory(t)),
+			manager.SaveFileName("download.bin"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = d.Initialize(); err != nil {
+			t.Fatal(err)
+		}
+
+		return d
+	}
+
+	var testCases = []struct {
+		name      string
+		operation func(t *testing.T) error
+		wantErr   error
+	}{
+		{
+			name: "Start twice",
+			operation: func(t *testing.T) error {
+				d := newDownload(t, server.URL)
+				if err := d.Start(); err != nil {
+					t.Fatal(err)
+				}
+
+				return d.Start()
+			},
+			wantErr: manager.ErrAlreadyStarted,
+		},
+		{
+			name: "Abort before the run begins",
+			operation: func(t *testing.T) error {
+				d := newDownload(t, server.URL)
+				d.Abort()
+
+				err := d.Start()
+				if d.IsDownloadRunning() || d.IsDownloadComplete() {
+					t.Errorf("Want aborted download not run, got running %v and complete %v",
+						d.IsDownloadRunning(), d.IsDownloadComplete())
+				}
+
+				return err
+			},
+			wantErr: manager.ErrAborted,
+		},
+		{
+			name: "Pause when not running",
+			operation: func(t *testing.T) error {
+				return newDownload(t, server.URL).Pause()
+			},
+			wantErr: manager.ErrNotRunning,
+		},
+		{
+			name: "Pause without range support",
+			operation: func(t *testing.T) error {
+				return newDownload(t, noRangeServer.URL).Pause()
+			},
+			wantErr: manager.ErrRangeNotSupported,
+		},
+		{
+			name: "Resume when not paused",
+			operation: func(t *testing.T) error {
+				return newDownload(t, server.URL).Resume()
+			},
+			wantErr: manager.ErrNotPaused,
+		},
+		{
+			name: "Missing save directory",
+			operation: func(t *testing.T) error {
+				_, err := manager.NewDownload(manager.SaveDirectory(filepath.Join(newTestDirectory(t), "missing")))
+				return err
+			},
+			wantErr: manager.ErrDirectoryNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := testCase.operation(t); !errors.Is(err, testCase.wantErr) {
+				t.Errorf("Want error %v, got %v", testCase.wantErr, err)
+			}
+		})
+	}
+}
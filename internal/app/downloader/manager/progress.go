@@ -0,0 +1,166 @@
+package manager
+
+import (
+	"sort"
+	"time"
+)
+
+// DefaultProgressInterval is the default interval at which the progress of a running download is reported.
+const DefaultProgressInterval = 500 * time.Millisecond
+
+// Progress is a snapshot of the progress of a download.
+type Progress struct {
+	// BytesCompleted is the number of bytes downloaded so far.
+	BytesCompleted int64
+
+	// FileSize is the size of the download file in bytes, or -1 if unknown.
+	FileSize int64
+
+	// Segments is the progress of each segment of the download ordered by byte range.
+	Segments []SegmentProgress
+
+	// Speed is the number of bytes downloaded per second since the last report.
+	Speed float64
+
+	// AverageSpeed is the number of bytes downloaded per second while the download was running.
+	AverageSpeed float64
+
+	// ETA is the estimated time remaining at the average speed, or -1 if unknown.
+	ETA time.Duration
+
+	// Elapsed is the time the download has been running, excluding the time it was paused.
+	Elapsed time.Duration
+}
+
+// SegmentProgress is a snapshot of the progress of a segment of a download.
+type SegmentProgress struct {
+	// RangeStart and RangeEnd are the inclusive byte range of the segment.
+	// RangeEnd is -1 while the end of a download of unknown length is not known.
+	RangeStart int64
+	RangeEnd   int64
+
+	// BytesCompleted is the number of bytes of the range downloaded so far.
+	BytesCompleted int64
+
+	// Speed is the number of bytes of the segment downloaded per second since the last report.
+	Speed float64
+}
+
+// progressTracker keeps track of the bytes and time of the runs of a download to calculate its speed.
+type progressTracker struct {
+	startBytes       int64         // Bytes completed when the download first ran in this process
+	elapsedBeforeRun time.Duration // Running time before the current run
+	runStartTime     time.Time
+	lastReportTime   time.Time
+	lastReportBytes  int64
+	lastSegmentBytes map[*Download]int64 // Bytes completed by each segment at the last report
+	isStarted        bool
+}
+
+// startRun starts tracking a run of the download and its segments.
+func (t *progressTracker) startRun(bytesCompleted int64, segments []*Download) {
+	if !t.isStarted {
+		t.startBytes = bytesCompleted
+		t.isStarted = true
+	}
+
+	t.runStartTime = time.Now()
+	t.lastReportTime = t.runStartTime
+	t.lastReportBytes = bytesCompleted
+
+	t.lastSegmentBytes = make(map[*Download]int64, len(segments))
+	for _, segment := range segments {
+		t.lastSegmentBytes[segment] = segment.BytesCompleted()
+	}
+}
+
+// stopRun stops tracking the current run of the download.
+func (t *progressTracker) stopRun() {
+	t.elapsedBeforeRun += time.Since(t.runStartTime)
+	t.runStartTime = time.Time{}
+}
+
+// elapsed returns the time the download has been running.
+func (t *progressTracker) elapsed() time.Duration {
+	if t.runStartTime.IsZero() {
+		return t.elapsedBeforeRun
+	}
+
+	return t.elapsedBeforeRun + time.Since(t.runStartTime)
+}
+
+// reportProgress calls the progress callback of the download with a snapshot of its progress.
+// It is only called by the goroutine running the segments.
+func (d *Download) reportProgress() {
+	if d.progressCallback == nil {
+		return
+	}
+
+	d.progressCallback(d.progress())
+}
+
+// progress returns a snapshot of the progress of the download and updates the speed tracked since the last report.
+func (d *Download) progress() Progress {
+	t := &d.progressTracker
+	now := time.Now()
+
+	p := Progress{
+		BytesCompleted: d.BytesCompleted(),
+		FileSize:       d.FileSize().Bytes(),
+		Elapsed:        t.elapsed(),
+		ETA:            -1,
+	}
+
+	interval := now.Sub(t.lastReportTime).Seconds()
+	isReportingSpeed := interval > 0 && !t.runStartTime.IsZero()
+
+	for _, segment := range d.segments() {
+		rangeStart, rangeEnd := segment.segmentRange()
+		if rangeEnd == streamRangeEnd {
+			rangeEnd = -1
+		}
+
+		segmentProgress := SegmentProgress{
+			RangeStart:     rangeStart,
+			RangeEnd:       rangeEnd,
+			BytesCompleted: segment.BytesCompleted(),
+		}
+
+		// A segment split since the last report started with no bytes
+		if isReportingSpeed {
+			segmentProgress.Speed = float64(segmentProgress.BytesCompleted-t.lastSegmentBytes[segment]) / interval
+		}
+
+		if t.lastSegmentBytes != nil {
+			t.lastSegmentBytes[segment] = segmentProgress.BytesCompleted
+		}
+
+		p.Segments = append(p.Segments, segmentProgress)
+	}
+
+	// Segments split while downloading are added at the end
+	sort.Slice(p.Segments, func(i, j int) bool {
+		return p.Segments[i].RangeStart < p.Segments[j].RangeStart
+	})
+
+	if isReportingSpeed {
+		p.Speed = float64(p.BytesCompleted-t.lastReportBytes) / interval
+	}
+
+	if elapsed := p.Elapsed.Seconds(); elapsed > 0 {
+		p.AverageSpeed = float64(p.BytesCompleted-t.startBytes) / elapsed
+	}
+
+	if p.FileSize >= 0 {
+		if remainingBytes := p.FileSize - p.BytesCompleted; remainingBytes <= 0 {
+			p.ETA = 0
+		} else if p.AverageSpeed > 0 {
+			p.ETA = time.Duration(float64(remainingBytes) / p.AverageSpeed * float64(time.Second))
+		}
+	}
+
+	t.lastReportTime = now
+	t.lastReportBytes = p.BytesCompleted
+
+	return p
+}
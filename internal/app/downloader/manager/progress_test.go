@@ -0,0 +1,92 @@
+package manager_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
+)
+
+func TestOnProgress(t *testing.T) {
+	const (
+		size                   = 512 * 1024
+		nrOfConcurrentDownload = 4
+	)
+
+	content := newTestContent(size)
+	g := newGate(32 * 1024)
+	server := newTestServer(content, g)
+	defer server.Close()
+
+	// The callback is called from the goroutine running the download
+	progressC := make(chan manager.Progress, 1024)
+
+	d, err := manager.NewDownload(
+		manager.DownloadURL(server.URL+"/download.bin"),
+		manager.NrOfConcurrentDownload(nrOfConcurrentDownload),
+		manager.SaveDirectory(newTestDirectory(t)),
+		manager.SaveFileName("download.bin"),
+		manager.ProgressInterval(time.Millisecond),
+		manager.OnProgress(func(p manager.Progress) {
+			progressC <- p
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan error, 1)
+	go func() {
+		started <- d.Start()
+	}()
+
+	// Progress is reported while the segments are held
+	for i := 0; i < nrOfConcurrentDownload; i++ {
+		<-g.blocked
+	}
+
+	var p manager.Progress
+	for p.BytesCompleted < int64(nrOfConcurrentDownload*g.after) {
+		p = <-progressC
+	}
+
+	if len(p.Segments) != nrOfConcurrentDownload {
+		t.Errorf("Want %d segments, got %d", nrOfConcurrentDownload, len(p.Segments))
+	}
+
+	for i, segment := range p.Segments {
+		if segment.BytesCompleted != int64(g.after) {
+			t.Errorf("Want segment %d bytes completed %d, got %d", i, g.after, segment.BytesCompleted)
+		}
+
+		if i > 0 && segment.RangeStart != p.Segments[i-1].RangeEnd+1 {
+			t.Errorf("Want segment %d to start at %d, got %d", i, p.Segments[i-1].RangeEnd+1, segment.RangeStart)
+		}
+	}
+
+	if p.FileSize != size {
+		t.Errorf("Want file size %d, got %d", size, p.FileSize)
+	}
+
+	if p.AverageSpeed <= 0 || p.ETA <= 0 {
+		t.Errorf("Want positive average speed and ETA, got %f and %s", p.AverageSpeed, p.ETA)
+	}
+
+	g.open()
+
+	if err = <-started; err != nil {
+		t.Fatal(err)
+	}
+
+	// The last progress is reported once the download stops
+	for len(progressC) > 0 {
+		p = <-progressC
+	}
+
+	if p.BytesCompleted != size || p.ETA != 0 {
+		t.Errorf("Want %d bytes completed and no ETA, got %d bytes and %s", size, p.BytesCompleted, p.ETA)
+	}
+}
@@ -0,0 +1,454 @@
+package manager
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxActiveDownloads is the default number of downloads a queue runs at the same time.
+	DefaultMaxActiveDownloads = 3
+
+	// queuePauseRetryInterval is the interval between attempts to pause a download that is still initializing.
+	queuePauseRetryInterval = 10 * time.Millisecond
+)
+
+// QueueConfigOption is the signature of functional option for Queue.
+type QueueConfigOption func(q *Queue) error
+
+// Queue runs many downloads, at most a maximum number of them at the same time.
+// Waiting downloads are started in order of priority, highest first,
+// and downloads of the same priority in the order they are added.
+type Queue struct {
+	maxActiveDownloads int
+	onDownloadDone     func(*Download, error)
+
+	isQueuePaused bool
+	waiting       []*queueItem // Ordered by priority, highest first
+	active        []*queueItem
+
+	mu   sync.Mutex
+	cond *sync.Cond // Signalled when a download leaves the active downloads
+}
+
+// queueItem is a download managed by a queue.
+type queueItem struct {
+	download *Download
+	priority int
+	removed  bool // Removed from the queue while active
+}
+
+// NewQueue create and returns a new Queue instance with configurations from the parameter input.
+func NewQueue(configurations ...QueueConfigOption) (*Queue, error) {
+	queue := &Queue{
+		maxActiveDownloads: DefaultMaxActiveDownloads,
+	}
+	queue.cond = sync.NewCond(&queue.mu)
+
+	for _, configuration := range configurations {
+		err := configuration(queue)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return queue, nil
+}
+
+// Functional options functions:
+
+// MaxActiveDownloads allows setting the number of downloads a queue runs at the same time.
+func MaxActiveDownloads(maxActiveDownloads int) QueueConfigOption {
+	return func(q *Queue) error {
+		return q.SetMaxActiveDownloads(maxActiveDownloads)
+	}
+}
+
+// OnDownloadDone allows setting the function called when a download leaves the queue,
+// with the error returned by the download if any.
+// It is called from the goroutine running the download.
+func OnDownloadDone(onDownloadDone func(*Download, error)) QueueConfigOption {
+	return func(q *Queue) error {
+		q.onDownloadDone = onDownloadDone
+
+		return nil
+	}
+}
+
+// MaxActiveDownloads returns the number of downloads the queue runs at the same time.
+func (q *Queue) MaxActiveDownloads() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.maxActiveDownloads
+}
+
+// SetMaxActiveDownloads sets the number of downloads the queue runs at the same time.
+// Lowering it lets the active downloads finish instead of pausing them.
+func (q *Queue) SetMaxActiveDownloads(maxActiveDownloads int) error {
+	if maxActiveDownloads <= 0 {
+		return errors.New("maximum number of active downloads must be larger than 0")
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.maxActiveDownloads = maxActiveDownloads
+	q.schedule()
+
+	return nil
+}
+
+// IsQueuePaused returns true if the queue is paused.
+func (q *Queue) IsQueuePaused() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.isQueuePaused
+}
+
+// Downloads returns the downloads in the queue, the active downloads first followed by the waiting downloads in order.
+func (q *Queue) Downloads() []*Download {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	downloads := make([]*Download, 0, len(q.active)+len(q.waiting))
+	for _, item := range q.active {
+		downloads = append(downloads, item.download)
+	}
+
+	for _, item := range q.waiting {
+		downloads = append(downloads, item.download)
+	}
+
+	return downloads
+}
+
+// Add adds a download to the queue with the given priority.
+// The download is initialized if needed and started, or resumed if it is paused, once it is its turn.
+func (q *Queue) Add(d *Download, priority int) error {
+	if d == nil {
+		return errors.New("download is nil")
+	}
+
+	if d.IsDownloadComplete() || d.IsDownloadAborted() || d.IsDownloadFailed() {
+		return errors.New("download has already finished")
+	}
+
+	if d.IsDownloadRunning() {
+		return ErrDownloadRunning
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.find(d) != nil {
+		return ErrAlreadyQueued
+	}
+
+	q.insert(&queueItem{download: d, priority: priority})
+	q.schedule()
+
+	return nil
+}
+
+// Remove removes a download from the queue.
+// An active download is aborted.
+func (q *Queue) Remove(d *Download) error {
+	q.mu.Lock()
+
+	if i := q.waitingIndex(d); i >= 0 {
+		q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+		q.mu.Unlock()
+
+		return nil
+	}
+
+	item := q.find(d)
+	if item == nil {
+		q.mu.Unlock()
+		return ErrNotQueued
+	}
+
+	item.removed = true
+	q.mu.Unlock()
+
+	d.Abort()
+
+	return nil
+}
+
+// SetPriority changes the priority of a download in the queue and moves it accordingly if it is waiting.
+func (q *Queue) SetPriority(d *Download, priority int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item := q.find(d)
+	if item == nil {
+		return ErrNotQueued
+	}
+
+	item.priority = priority
+
+	if i := q.waitingIndex(d); i >= 0 {
+		q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+		q.insert(item)
+	}
+
+	return nil
+}
+
+// Move moves a waiting download to the given position among the waiting downloads.
+// The priority of the download is changed to the priority of the download it is moved in front of,
+// or behind when it is moved to the end, so the waiting downloads stay ordered by priority.
+func (q *Queue) Move(d *Download, position int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.waitingIndex(d)
+	if i < 0 {
+		return errors.New("download is not waiting in the queue")
+	}
+
+	if position < 0 || position >= len(q.waiting) {
+		return errors.New("position is out of range")
+	}
+
+	item := q.waiting[i]
+	q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+
+	// Insert the download at the position
+	q.waiting = append(q.waiting, nil)
+	copy(q.waiting[position+1:], q.waiting[position:])
+	q.waiting[position] = item
+
+	if position+1 < len(q.waiting) {
+		item.priority = q.waiting[position+1].priority
+	} else if position > 0 {
+		item.priority = q.waiting[position-1].priority
+	}
+
+	return nil
+}
+
+// MoveToTop moves a waiting download to the top of the queue, so it is the next download to start.
+func (q *Queue) MoveToTop(d *Download) error {
+	return q.Move(d, 0)
+}
+
+// Pause pauses the queue and all the active downloads that can be paused.
+// The paused downloads are put back in front of the waiting downloads of the same priority.
+// Active downloads that cannot be paused are left to finish.
+// It blocks until the active downloads are paused.
+func (q *Queue) Pause() error {
+	q.mu.Lock()
+
+	if q.isQueuePaused {
+		q.mu.Unlock()
+		return errors.New("queue is already paused")
+	}
+
+	q.isQueuePaused = true
+	active := append([]*queueItem(nil), q.active...)
+
+	q.mu.Unlock()
+
+	for _, item := range active {
+		// A download still initializing is paused once it is running
+		for q.isActive(item) && item.download.IsPauseAllowed() != notAllowed {
+			if err := item.download.Pause(); err == nil {
+				break
+			}
+
+			time.Sleep(queuePauseRetryInterval)
+		}
+	}
+
+	// Wait for the paused downloads to be put back in the queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, item := range active {
+		for q.activeIndex(item) >= 0 && item.download.IsPauseAllowed() != notAllowed {
+			q.cond.Wait()
+		}
+	}
+
+	// The downloads may stop in any order, so they are put back in the order they were started
+	for i := len(active) - 1; i >= 0; i-- {
+		if j := q.waitingIndex(active[i].download); j >= 0 {
+			q.waiting = append(q.waiting[:j], q.waiting[j+1:]...)
+			q.requeue(active[i])
+		}
+	}
+
+	return nil
+}
+
+// Resume resumes the queue, starting the waiting downloads in order.
+func (q *Queue) Resume() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.isQueuePaused {
+		return errors.New("queue is not paused")
+	}
+
+	q.isQueuePaused = false
+	q.schedule()
+
+	return nil
+}
+
+// Wait blocks until every download has left the queue.
+func (q *Queue) Wait() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.active) > 0 || len(q.waiting) > 0 {
+		q.cond.Wait()
+	}
+}
+
+// insert inserts a download in the waiting downloads after the downloads of the same or higher priority.
+// The caller must hold the queue lock.
+func (q *Queue) insert(item *queueItem) {
+	i := 0
+	for i < len(q.waiting) && q.waiting[i].priority >= item.priority {
+		i++
+	}
+
+	q.waiting = append(q.waiting, nil)
+	copy(q.waiting[i+1:], q.waiting[i:])
+	q.waiting[i] = item
+}
+
+// requeue puts a paused download back in front of the waiting downloads of the same or lower priority.
+// The caller must hold the queue lock.
+func (q *Queue) requeue(item *queueItem) {
+	i := 0
+	for i < len(q.waiting) && q.waiting[i].priority > item.priority {
+		i++
+	}
+
+	q.waiting = append(q.waiting, nil)
+	copy(q.waiting[i+1:], q.waiting[i:])
+	q.waiting[i] = item
+}
+
+// schedule starts the waiting downloads in order while there are free slots.
+// The caller must hold the queue lock.
+func (q *Queue) schedule() {
+	for !q.isQueuePaused && len(q.waiting) > 0 && len(q.active) < q.maxActiveDownloads {
+		item := q.waiting[0]
+		q.waiting = q.waiting[1:]
+		q.active = append(q.active, item)
+
+		go q.run(item)
+	}
+}
+
+// run runs a download until it stops and schedules the next waiting downloads.
+func (q *Queue) run(item *queueItem) {
+	d := item.download
+
+	var err error
+	if d.IsDownloadPaused() {
+		err = d.Resume()
+	} else {
+		if !d.IsDownloadInitialized() {
+			err = d.Initialize()
+		}
+
+		// A download removed while initializing is not started
+		if err == nil && q.isRemoved(item) {
+			err = ErrNotQueued
+		}
+
+		if err == nil {
+			err = d.Start()
+		}
+	}
+
+	// A download paused with the queue waits for the queue to be resumed
+	q.mu.Lock()
+	requeued := err == nil && !item.removed && q.isQueuePaused && d.IsDownloadPaused()
+	q.mu.Unlock()
+
+	// The download leaves the queue after the function is called, so Wait returns after it
+	if !requeued && q.onDownloadDone != nil {
+		q.onDownloadDone(d, err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if i := q.activeIndex(item); i >= 0 {
+		q.active = append(q.active[:i], q.active[i+1:]...)
+	}
+
+	if requeued {
+		q.requeue(item)
+	}
+
+	q.schedule()
+	q.cond.Broadcast()
+}
+
+// find returns the queue item of a download, or nil if the download is not in the queue.
+// The caller must hold the queue lock.
+func (q *Queue) find(d *Download) *queueItem {
+	for _, item := range q.active {
+		if item.download == d {
+			return item
+		}
+	}
+
+	if i := q.waitingIndex(d); i >= 0 {
+		return q.waiting[i]
+	}
+
+	return nil
+}
+
+// waitingIndex returns the position of a download in the waiting downloads, or -1 if it is not waiting.
+// The caller must hold the queue lock.
+func (q *Queue) waitingIndex(d *Download) int {
+	for i, item := range q.waiting {
+		if item.download == d {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// activeIndex returns the position of a queue item in the active downloads, or -1 if it is not active.
+// The caller must hold the queue lock.
+func (q *Queue) activeIndex(item *queueItem) int {
+	for i, activeItem := range q.active {
+		if activeItem == item {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// isActive returns true if a queue item is in the active downloads.
+func (q *Queue) isActive(item *queueItem) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.activeIndex(item) >= 0
+}
+
+// isRemoved returns true if a queue item has been removed from the queue while active.
+func (q *Queue) isRemoved(item *queueItem) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return item.removed
+}
@@ -0,0 +1,322 @@
+package manager_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
+)
+
+// queueRecorder records the downloads leaving a queue in order.
+type queueRecorder struct {
+	mu        sync.Mutex
+	downloads []*manager.Download
+	errs      []error
+}
+
+func (r *queueRecorder) done(d *manager.Download, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.downloads = append(r.downloads, d)
+	r.errs = append(r.errs, err)
+}
+
+func newQueueDownloads(t *testing.T, url string, directory string, n int) []*manager.Download {
+	downloads := make([]*manager.Download, n)
+
+	for i := range downloads {
+		d, err := manager.NewDownload(
+			manager.DownloadURL(url),
+			manager.NrOfConcurrentDownload(2),
+			manager.SaveDirectory(directory),
+			manager.SaveFileName("download"+strconv.Itoa(i)+".bin"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		downloads[i] = d
+	}
+
+	return downloads
+}
+
+func TestQueueOrder(t *testing.T) {
+	content := newTestContent(64 * 1024)
+	server := newTestServer(content, nil)
+	defer server.Close()
+
+	recorder := &queueRecorder{}
+
+	q, err := manager.NewQueue(manager.MaxActiveDownloads(1), manager.OnDownloadDone(recorder.done))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Order the downloads while the queue is paused
+	if err = q.Pause(); err != nil {
+		t.Fatal(err)
+	}
+
+	downloads := newQueueDownloads(t, server.URL+"/download.bin", newTestDirectory(t), 5)
+	priorities := []int{0, 1, 0, 2, 1}
+
+	for i, d := range downloads {
+		if err = q.Add(d, priorities[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = q.Add(downloads[0], 0); err == nil {
+		t.Errorf("Want error adding a download twice")
+	}
+
+	var testCases = []struct {
+		name   string
+		change func() error
+		want   []int
+	}{
+		{name: "Priority", change: func() error { return nil }, want: []int{3, 1, 4, 0, 2}},
+		{name: "Move to top", change: func() error { return q.MoveToTop(downloads[2]) }, want: []int{2, 3, 1, 4, 0}},
+		{name: "Move", change: func() error { return q.Move(downloads[3], 4) }, want: []int{2, 1, 4, 0, 3}},
+		{name: "Set priority", change: func() error { return q.SetPriority(downloads[0], 3) }, want: []int{0, 2, 1, 4, 3}},
+		{name: "Remove", change: func() error { return q.Remove(downloads[4]) }, want: []int{0, 2, 1, 3}},
+	}
+
+	for _, testCase := range testCases {
+		if err = testCase.change(); err != nil {
+			t.Fatalf("%s: %v", testCase.name, err)
+		}
+
+		get := q.Downloads()
+		if len(get) != len(testCase.want) {
+			t.Fatalf("%s: Want %d downloads, got %d", testCase.name, len(testCase.want), len(get))
+		}
+
+		for i, want := range testCase.want {
+			if get[i] != downloads[want] {
+				t.Errorf("%s: Want download %d at position %d", testCase.name, want, i)
+			}
+		}
+	}
+
+	if err = q.Resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	q.Wait()
+
+	// Downloads run one at a time in the queue order
+	for i, want := range []int{0, 2, 1, 3} {
+		if recorder.downloads[i] != downloads[want] || recorder.errs[i] != nil {
+			t.Errorf("Want download %d done at position %d without error, got %v", want, i, recorder.errs[i])
+		}
+
+		if !downloads[want].IsDownloadComplete() {
+			t.Errorf("Want download %d complete", want)
+		}
+	}
+}
+
+func TestQueuePauseResume(t *testing.T) {
+	const (
+		size               = 256 * 1024
+		maxActiveDownloads = 2
+		nrOfDownloads      = 4
+	)
+
+	content := newTestContent(size)
+	g := newGate(32 * 1024)
+	server := newTestServer(content, g)
+	defer server.Close()
+
+	directory := newTestDirectory(t)
+	recorder := &queueRecorder{}
+
+	q, err := manager.NewQueue(manager.MaxActiveDownloads(maxActiveDownloads), manager.OnDownloadDone(recorder.done))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	downloads := newQueueDownloads(t, server.URL+"/download.bin", directory, nrOfDownloads)
+	for _, d := range downloads {
+		if err = q.Add(d, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only the maximum number of downloads are running
+	waitFor(t, func() bool {
+		return downloads[0].BytesCompleted() == 2*int64(g.after) && downloads[1].BytesCompleted() == 2*int64(g.after)
+	})
+
+	for i, d := range downloads {
+		if d.IsDownloadStarted() != (i < maxActiveDownloads) {
+			t.Errorf("Want download %d started %t, got %t", i, i < maxActiveDownloads, d.IsDownloadStarted())
+		}
+	}
+
+	if err = q.Pause(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The paused downloads stay in front of the queue
+	for i, d := range q.Downloads() {
+		if d != downloads[i] {
+			t.Errorf("Want download %d at position %d", i, i)
+		}
+	}
+
+	for i := 0; i < maxActiveDownloads; i++ {
+		if !downloads[i].IsDownloadPaused() {
+			t.Errorf("Want download %d paused", i)
+		}
+	}
+
+	g.open()
+
+	if err = q.Resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	q.Wait()
+
+	if len(recorder.downloads) != nrOfDownloads {
+		t.Fatalf("Want %d downloads done, got %d", nrOfDownloads, len(recorder.downloads))
+	}
+
+	for i, d := range downloads {
+		if recorder.errs[i] != nil {
+			t.Errorf("Want no error, got %v", recorder.errs[i])
+		}
+
+		get, err := ioutil.ReadFile(filepath.Join(directory, "download"+strconv.Itoa(i)+".bin"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !d.IsDownloadComplete() || !bytes.Equal(content, get) {
+			t.Errorf("Want download %d complete with %d bytes of content, got %d bytes", i, len(content), len(get))
+		}
+	}
+}
+
+// newProbeBlockingServer returns a test server holding the HEAD requests until the release channel is closed
+// or the request is cancelled, and a channel receiving a value for every HEAD request held.
+func newProbeBlockingServer(content []byte, g *gate, release <-chan struct{}) (*httptest.Server, <-chan struct{}) {
+	probed := make(chan struct{}, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			probed <- struct{}{}
+
+			select {
+			case <-release:
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if g != nil && r.Header.Get("Range") != "" {
+			w = &gatedWriter{ResponseWriter: w, request: r, gate: g}
+		}
+
+		http.ServeContent(w, r, "download.bin", time.Time{}, bytes.NewReader(content))
+	}))
+
+	return server, probed
+}
+
+func TestQueueRemoveInitializing(t *testing.T) {
+	release := make(chan struct{})
+	server, probed := newProbeBlockingServer(newTestContent(64*1024), nil, release)
+	defer server.Close()
+	defer close(release)
+
+	recorder := &queueRecorder{}
+
+	q, err := manager.NewQueue(manager.OnDownloadDone(recorder.done))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := newQueueDownloads(t, server.URL+"/download.bin", newTestDirectory(t), 1)[0]
+	if err = q.Add(d, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// The download is aborted while its HEAD request is in flight
+	<-probed
+
+	if err = q.Remove(d); err != nil {
+		t.Fatal(err)
+	}
+
+	q.Wait()
+
+	if len(recorder.errs) != 1 || !errors.Is(recorder.errs[0], manager.ErrAborted) {
+		t.Fatalf("Want error %v, got %v", manager.ErrAborted, recorder.errs)
+	}
+
+	if d.IsDownloadStarted() || !d.IsDownloadAborted() {
+		t.Errorf("Want download aborted and not started, got started %t and aborted %t", d.IsDownloadStarted(), d.IsDownloadAborted())
+	}
+}
+
+func TestQueuePauseInitializing(t *testing.T) {
+	const size = 256 * 1024
+
+	content := newTestContent(size)
+	g := newGate(32 * 1024)
+	release := make(chan struct{})
+	server, probed := newProbeBlockingServer(content, g, release)
+	defer server.Close()
+
+	q, err := manager.NewQueue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := newQueueDownloads(t, server.URL+"/download.bin", newTestDirectory(t), 1)[0]
+	if err = q.Add(d, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	<-probed
+
+	// The queue is paused while the download is initializing and the download is paused once it is running
+	paused := make(chan error, 1)
+	go func() {
+		paused <- q.Pause()
+	}()
+
+	close(release)
+
+	if err = <-paused; err != nil {
+		t.Fatal(err)
+	}
+
+	if !d.IsDownloadPaused() {
+		t.Error("Want download paused")
+	}
+
+	g.open()
+
+	if err = q.Resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	q.Wait()
+
+	if !d.IsDownloadComplete() {
+		t.Error("Want download complete")
+	}
+}
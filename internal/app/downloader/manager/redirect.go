@@ -0,0 +1,128 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// DefaultMaxRedirects is the default number of redirects followed by a request.
+const DefaultMaxRedirects = 10
+
+// Redirects returns the URLs redirected from to reach the effective URL in Initialize,
+// starting with the download URL, or nil if the download URL was not redirected.
+func (d *Download) Redirects() []string {
+	if len(d.redirects) == 0 {
+		return nil
+	}
+
+	redirects := make([]string, len(d.redirects))
+	for i, redirect := range d.redirects {
+		redirects[i] = redirect.String()
+	}
+
+	return redirects
+}
+
+// EffectiveURL returns the URL the download URL redirected to in Initialize,
+// which the segments download from. It is the download URL if it was not redirected.
+func (d *Download) EffectiveURL() string {
+	if d.effectiveURL == nil {
+		return d.DownloadURL()
+	}
+
+	return d.effectiveURL.String()
+}
+
+// MaxRedirects returns the number of redirects followed by a request.
+func (d *Download) MaxRedirects() int {
+	return d.maxRedirects
+}
+
+// SetMaxRedirects sets the number of redirects followed by a request
+// and returns a non nil error if failed to set.
+// A request redirected more often fails with ErrRedirectNotAllowed, and 0 does not follow redirects.
+func (d *Download) SetMaxRedirects(maxRedirects int) error {
+	if maxRedirects < 0 {
+		return errors.New("maximum number of redirects cannot be negative")
+	}
+
+	d.maxRedirects = maxRedirects
+
+	return nil
+}
+
+// IsCrossSchemeRedirectAllowed returns a boolean indicating whether a request follows redirects
+// to another scheme, such as from HTTPS to HTTP.
+func (d *Download) IsCrossSchemeRedirectAllowed() bool {
+	return d.isCrossSchemeRedirectAllowed
+}
+
+// SetCrossSchemeRedirect sets whether a request follows redirects to another scheme, such as from HTTPS to HTTP.
+// A request redirected to another scheme when not allowed fails with ErrRedirectNotAllowed.
+func (d *Download) SetCrossSchemeRedirect(isCrossSchemeRedirectAllowed bool) error {
+	d.isCrossSchemeRedirectAllowed = isCrossSchemeRedirectAllowed
+
+	return nil
+}
+
+// redirectingClient returns a copy of the HTTP client applying the redirect policy of the download
+// before the redirect policy of the client, and authorizing the requests redirected to.
+func (d *Download) redirectingClient() *http.Client {
+	client := *d.HTTPClient()
+
+	checkRedirect := client.CheckRedirect
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if err := d.checkRedirect(req, via); err != nil {
+			return err
+		}
+
+		if checkRedirect != nil {
+			if err := checkRedirect(req, via); err != nil {
+				return err
+			}
+		}
+
+		// The client drops the Authorization header on redirects to another host,
+		// so the challenges of the host redirected to are answered here
+		d.authorize(req)
+
+		return nil
+	}
+
+	return &client
+}
+
+// checkRedirect returns a non nil error if the redirect to the request is not allowed by the redirect policy.
+// The requests sent before are in via, oldest first.
+func (d *Download) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > d.MaxRedirects() {
+		return fmt.Errorf("%w: stopped after %d redirects", ErrRedirectNotAllowed, d.MaxRedirects())
+	}
+
+	if previous := via[len(via)-1].URL; req.URL.Scheme != previous.Scheme && !d.IsCrossSchemeRedirectAllowed() {
+		return fmt.Errorf("%w: redirect from %s to %s", ErrRedirectNotAllowed, previous.Scheme, req.URL.Scheme)
+	}
+
+	return nil
+}
+
+// setRedirects records the redirect chain of the response and its final URL as the effective URL.
+func (d *Download) setRedirects() {
+	var redirects []*url.URL
+	for req := d.response.Request; req.Response != nil; req = req.Response.Request {
+		redirects = append([]*url.URL{req.Response.Request.URL}, redirects...)
+	}
+
+	d.redirects = redirects
+	d.effectiveURL = d.response.Request.URL
+}
+
+// isRedirectTargetExpired returns a boolean indicating whether a segment failed
+// because the URL redirected to is not valid anymore, such as an expired or one-time signed URL.
+func isRedirectTargetExpired(err error) bool {
+	var statusErr *HTTPStatusError
+
+	return errors.As(err, &statusErr) && !isTransientError(err)
+}
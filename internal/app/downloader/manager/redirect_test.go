@@ -0,0 +1,275 @@
+package manager_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
+)
+
+// redirectServer redirects /download to /redirect and /redirect to a new signed URL.
+// One-time signed URLs are only served once.
+type redirectServer struct {
+	*httptest.Server
+
+	content   []byte
+	isOneTime bool
+
+	mu                sync.Mutex
+	nrOfTokens        int
+	usedTokens        map[string]bool
+	nrOfDownloadHits  int
+	nrOfSignedRejects int
+}
+
+func newRedirectServer(content []byte, isOneTime bool) *redirectServer {
+	s := &redirectServer{content: content, isOneTime: isOneTime, usedTokens: make(map[string]bool)}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		switch {
+		case r.URL.Path == "/download":
+			s.nrOfDownloadHits++
+			http.Redirect(w, r, "/redirect", http.StatusFound)
+		case r.URL.Path == "/insecure":
+			http.Redirect(w, r, "https://"+r.Host+"/redirect", http.StatusFound)
+		case r.URL.Path == "/redirect":
+			s.nrOfTokens++
+			http.Redirect(w, r, "/signed/"+strconv.Itoa(s.nrOfTokens)+"/download.bin", http.StatusFound)
+		case strings.HasPrefix(r.URL.Path, "/signed/"):
+			if s.isOneTime && s.usedTokens[r.URL.Path] {
+				s.nrOfSignedRejects++
+				w.WriteHeader(http.StatusForbidden)
+
+				return
+			}
+
+			s.usedTokens[r.URL.Path] = true
+			http.ServeContent(w, r, "download.bin", time.Time{}, bytes.NewReader(s.content))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	return s
+}
+
+func TestRedirect(t *testing.T) {
+	const size = 512 * 1024
+
+	content := newTestContent(size)
+
+	var testCases = []struct {
+		name      string
+		isOneTime bool
+	}{
+		{name: "Segments download from the effective URL"},
+		{name: "Fall back to the download URL when the effective URL expires", isOneTime: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := newRedirectServer(content, testCase.isOneTime)
+			defer server.Close()
+
+			directory := newTestDirectory(t)
+
+			d, err := manager.NewDownload(
+				manager.DownloadURL(server.URL+"/download"),
+				manager.NrOfConcurrentDownload(4),
+				manager.SaveDirectory(directory))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = d.Initialize(); err != nil {
+				t.Fatal(err)
+			}
+
+			wantRedirects := []string{server.URL + "/download", server.URL + "/redirect"}
+			if !reflect.DeepEqual(d.Redirects(), wantRedirects) {
+				t.Errorf("Want redirects %v, got %v", wantRedirects, d.Redirects())
+			}
+
+			wantEffectiveURL := server.URL + "/signed/1/download.bin"
+			if d.EffectiveURL() != wantEffectiveURL {
+				t.Errorf("Want effective URL %v, got %v", wantEffectiveURL, d.EffectiveURL())
+			}
+
+			if err = d.Start(); err != nil {
+				t.Fatal(err)
+			}
+
+			get, err := ioutil.ReadFile(filepath.Join(directory, "download.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(content, get) {
+				t.Errorf("Want %d bytes of content, got %d bytes that differ", len(content), len(get))
+			}
+
+			server.mu.Lock()
+			defer server.mu.Unlock()
+
+			// Only the probe is redirected unless the effective URL expires
+			if !testCase.isOneTime && server.nrOfDownloadHits != 1 {
+				t.Errorf("Want 1 request to the download URL, got %d", server.nrOfDownloadHits)
+			}
+
+			if testCase.isOneTime && server.nrOfSignedRejects == 0 {
+				t.Errorf("Want requests to the expired effective URL rejected")
+			}
+		})
+	}
+}
+
+func TestRedirectPolicy(t *testing.T) {
+	content := newTestContent(1024)
+
+	var testCases = []struct {
+		name          string
+		path          string
+		options       []manager.ConfigOption
+		wantErr       error
+		wantRedirects []string
+	}{
+		{name: "Not redirected", path: "/signed/1/download.bin"},
+		{name: "Within maximum redirects", path: "/download", options: []manager.ConfigOption{manager.MaxRedirects(2)}, wantRedirects: []string{"/download", "/redirect"}},
+		{name: "Too many redirects", path: "/download", options: []manager.ConfigOption{manager.MaxRedirects(1)}, wantErr: manager.ErrRedirectNotAllowed},
+		{name: "No redirects", path: "/download", options: []manager.ConfigOption{manager.MaxRedirects(0)}, wantErr: manager.ErrRedirectNotAllowed},
+		{name: "Cross scheme redirect", path: "/insecure", options: []manager.ConfigOption{manager.CrossSchemeRedirect(false)}, wantErr: manager.ErrRedirectNotAllowed},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := newRedirectServer(content, false)
+			defer server.Close()
+
+			d, err := manager.NewDownload(append([]manager.ConfigOption{
+				manager.DownloadURL(server.URL + testCase.path),
+				manager.SaveDirectory(newTestDirectory(t))},
+				testCase.options...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = d.Initialize(); !errors.Is(err, testCase.wantErr) {
+				t.Errorf("Want error %v, got %v", testCase.wantErr, err)
+			}
+
+			if testCase.wantErr != nil {
+				return
+			}
+
+			// The redirects are nil if the download URL was not redirected
+			var wantRedirects []string
+			for _, path := range testCase.wantRedirects {
+				wantRedirects = append(wantRedirects, server.URL+path)
+			}
+
+			if !reflect.DeepEqual(d.Redirects(), wantRedirects) {
+				t.Errorf("Want redirects %#v, got %#v", wantRedirects, d.Redirects())
+			}
+		})
+	}
+}
+
+func TestRedirectCredentials(t *testing.T) {
+	const size = 512 * 1024
+
+	content := newTestContent(size)
+
+	var testCases = []struct {
+		name              string
+		option            manager.ConfigOption
+		wantAuthorization string
+	}{
+		{name: "Bearer token", option: manager.BearerToken(testToken), wantAuthorization: "Bearer " + testToken},
+		{name: "Basic authentication", option: manager.BasicAuth(testUsername, testPassword), wantAuthorization: "Basic dXNlcjpzZWNyZXQ="},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var nrOfCDNRequests int
+			var cdnAuthorizations []string
+
+			// The origin redirects to a CDN on another host
+			cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				nrOfCDNRequests++
+				if authorization := r.Header.Get("Authorization"); authorization != "" {
+					cdnAuthorizations = append(cdnAuthorizations, authorization)
+				}
+				mu.Unlock()
+
+				http.ServeContent(w, r, "download.bin", time.Time{}, bytes.NewReader(content))
+			}))
+			defer cdn.Close()
+
+			origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != testCase.wantAuthorization {
+					w.Header().Set("WWW-Authenticate", `Basic realm="`+testRealm+`"`)
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				// The CDN is reached by another host name, as credentials are kept on redirects to the same host name
+				http.Redirect(w, r, strings.Replace(cdn.URL, "127.0.0.1", "localhost", 1)+"/signed/download.bin", http.StatusFound)
+			}))
+			defer origin.Close()
+
+			directory := newTestDirectory(t)
+
+			d, err := manager.NewDownload(
+				manager.DownloadURL(origin.URL+"/download.bin"),
+				manager.NrOfConcurrentDownload(4),
+				manager.SaveDirectory(directory),
+				testCase.option)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = d.Initialize(); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = d.Start(); err != nil {
+				t.Fatal(err)
+			}
+
+			get, err := ioutil.ReadFile(filepath.Join(directory, "download.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(content, get) {
+				t.Errorf("Want %d bytes of content, got %d bytes that differ", len(content), len(get))
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if nrOfCDNRequests < 2 {
+				t.Errorf("Want the segments downloaded from the CDN, got %d requests", nrOfCDNRequests)
+			}
+
+			if len(cdnAuthorizations) != 0 {
+				t.Errorf("Want no credentials sent to the CDN, got %v", cdnAuthorizations)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultMaxRetries is the default number of times a failed segment is retried.
+	DefaultMaxRetries = 5
+
+	// DefaultRetryMinBackoff is the default delay before the first retry of a failed segment.
+	DefaultRetryMinBackoff = time.Second
+
+	// DefaultRetryMaxBackoff is the default maximum delay between retries of a failed segment.
+	DefaultRetryMaxBackoff = 30 * time.Second
+
+	// DefaultRetryJitter is the default fraction of the retry delay that is randomized.
+	DefaultRetryJitter = 0.5
+)
+
+// downloadSegmentWithRetry downloads a segment and retries it from its current offset
+// with exponential backoff if it fails with a transient error.
+// The number of retries is reset whenever the segment makes progress.
+// With mirrors, the segment continues from another mirror when its mirror fails.
+func (d *Download) downloadSegmentWithRetry(segment *Download) error {
+	retry := 0
+
+	for {
+		bytesCompleted := segment.BytesCompleted()
+
+		err := segment.downloadSegment()
+		if err == nil || d.ctx.Err() != nil {
+			return err
+		}
+
+		if segment.BytesCompleted() > bytesCompleted {
+			retry = 0
+		}
+
+		// A segment moves to another mirror when its mirror fails,
+		// and a mirror that is not used anymore is left straight away
+		if d.switchMirror(segment, err) && !isTransientError(err) {
+			continue
+		}
+
+		if !isTransientError(err) || retry >= d.MaxRetries() {
+			return err
+		}
+
+		backoff := d.retryBackoff(retry)
+		retry++
+
+		d.Logger().Warn("Retrying segment", "url", segment.downloadURL, "retry", retry, "backoff", backoff, "error", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-d.ctx.Done():
+			return d.ctx.Err()
+		}
+	}
+}
+
+// retryBackoff returns the delay before the given retry, which doubles on every retry up to the maximum backoff.
+// A random part of the delay, given by the retry jitter, is subtracted to spread out retries of different segments.
+func (d *Download) retryBackoff(retry int) time.Duration {
+	backoff := float64(d.RetryMaxBackoff())
+	if exponent := float64(d.RetryMinBackoff()) * math.Pow(2, float64(retry)); exponent < backoff {
+		backoff = exponent
+	}
+
+	backoff -= backoff * d.RetryJitter() * rand.Float64()
+
+	return time.Duration(backoff)
+}
+
+// isTransientError returns a boolean indicating whether a failed request may succeed when retried.
+// Timeouts, connection errors and server errors are transient,
+// while client errors such as 404, 410 and 416, certificate, protocol and file system errors are fatal.
+func isTransientError(err error) bool {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError ||
+			statusErr.StatusCode == http.StatusRequestTimeout ||
+			statusErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	// Stopped by Pause or Abort, or redirected against the redirect policy
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrRedirectNotAllowed) {
+		return false
+	}
+
+	// File system errors have to be checked before network errors as they wrap system call errors
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return false
+	}
+
+	// A failed request returns a *url.Error for any error, so the error it wraps is classified instead
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+
+	// The connection was closed before the response or the segment was complete
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+
+	// Timeouts of the connection, the response header or reading the body
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	// Connections that cannot be dialed, including DNS errors, or broke while reading
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return opErr.Op == "dial" || opErr.Op == "read"
+	}
+
+	return false
+}
@@ -0,0 +1,209 @@
+package manager_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
+)
+
+// abortingWriter aborts the response after writing a number of bytes.
+type abortingWriter struct {
+	http.ResponseWriter
+	after   int
+	written int
+}
+
+func (w *abortingWriter) Write(p []byte) (int, error) {
+	if w.written+len(p) > w.after {
+		p = p[:w.after-w.written]
+		n, _ := w.ResponseWriter.Write(p)
+		w.written += n
+
+		if f, ok := w.ResponseWriter.(http.Flusher); ok {
+			f.Flush()
+		}
+
+		panic(http.ErrAbortHandler)
+	}
+
+	n, err := w.ResponseWriter.Write(p)
+	w.written += n
+
+	return n, err
+}
+
+func TestRetry(t *testing.T) {
+	const (
+		size                   = 256 * 1024
+		nrOfConcurrentDownload = 4
+		nrOfFailedRequests     = 6
+	)
+
+	var testCases = []struct {
+		name       string
+		maxRetries int
+		fail       func(w http.ResponseWriter) http.ResponseWriter
+		wantErr    bool
+	}{
+		{
+			name:       "Server error",
+			maxRetries: 3,
+			fail: func(w http.ResponseWriter) http.ResponseWriter {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return nil
+			},
+		},
+		{
+			name:       "Connection closed",
+			maxRetries: 3,
+			fail: func(w http.ResponseWriter) http.ResponseWriter {
+				return &abortingWriter{ResponseWriter: w, after: 16 * 1024}
+			},
+		},
+		{
+			name:       "Not found",
+			maxRetries: 3,
+			fail: func(w http.ResponseWriter) http.ResponseWriter {
+				w.WriteHeader(http.StatusNotFound)
+				return nil
+			},
+			wantErr: true,
+		},
+		{
+			name:       "Out of retries",
+			maxRetries: 0,
+			fail: func(w http.ResponseWriter) http.ResponseWriter {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return nil
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			content := newTestContent(size)
+			var nrOfRangeRequests int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Range") != "" && atomic.AddInt32(&nrOfRangeRequests, 1) <= nrOfFailedRequests {
+					if w = testCase.fail(w); w == nil {
+						return
+					}
+				}
+
+				http.ServeContent(w, r, "download.bin", time.Time{}, bytes.NewReader(content))
+			}))
+			defer server.Close()
+
+			directory := newTestDirectory(t)
+
+			d, err := manager.NewDownload(
+				manager.DownloadURL(server.URL+"/download.bin"),
+				manager.NrOfConcurrentDownload(nrOfConcurrentDownload),
+				manager.SaveDirectory(directory),
+				manager.SaveFileName("download.bin"),
+				manager.MaxRetries(testCase.maxRetries),
+				manager.RetryBackoff(time.Millisecond, 10*time.Millisecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = d.Initialize(); err != nil {
+				t.Fatal(err)
+			}
+
+			err = d.Start()
+			if testCase.wantErr {
+				var statusErr *manager.HTTPStatusError
+				if !errors.As(err, &statusErr) {
+					t.Fatalf("Want HTTP status error, got %v", err)
+				}
+
+				if d.IsDownloadComplete() {
+					t.Errorf("Want download not complete")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			get, err := ioutil.ReadFile(filepath.Join(directory, "download.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(content, get) {
+				t.Errorf("Want %d bytes of content, got %d bytes that differ", len(content), len(get))
+			}
+		})
+	}
+}
+
+// requestErr returns the error of a GET request to the URL with the default client.
+func requestErr(t *testing.T, rawURL string) error {
+	t.Helper()
+
+	response, err := http.Get(rawURL)
+	if err == nil {
+		_ = response.Body.Close()
+		t.Fatalf("Want error requesting %s, got nil", rawURL)
+	}
+
+	return err
+}
+
+func TestIsTransientError(t *testing.T) {
+	// The default client does not trust the certificate of the TLS test server
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsServer.Close()
+
+	// Nothing listens on the address of a closed server
+	closedServer := httptest.NewServer(http.NotFoundHandler())
+	closedServer.Close()
+
+	var testCases = []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "Server error", err: &manager.HTTPStatusError{StatusCode: http.StatusServiceUnavailable}, want: true},
+		{name: "Too many requests", err: &manager.HTTPStatusError{StatusCode: http.StatusTooManyRequests}, want: true},
+		{name: "Not found", err: &manager.HTTPStatusError{StatusCode: http.StatusNotFound}},
+		{name: "Connection refused", err: requestErr(t, closedServer.URL), want: true},
+		{name: "Timeout", err: &url.Error{Op: "Get", URL: tlsServer.URL, Err: context.DeadlineExceeded}, want: true},
+		{name: "Connection closed", err: fmt.Errorf("segment: %w", io.ErrUnexpectedEOF), want: true},
+		{name: "Connection reset", err: &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, want: true},
+		{name: "Certificate", err: requestErr(t, tlsServer.URL)},
+		{name: "Unsupported scheme", err: requestErr(t, "ftp://example.com/download.bin")},
+		{name: "Malformed response", err: &url.Error{Op: "Get", URL: tlsServer.URL, Err: errors.New("malformed HTTP response")}},
+		{name: "Cancelled", err: &url.Error{Op: "Get", URL: tlsServer.URL, Err: context.Canceled}},
+		{name: "File system", err: &os.PathError{Op: "open", Path: "download.bin", Err: syscall.ENOSPC}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if get := manager.IsTransientError(testCase.err); get != testCase.want {
+				t.Errorf("Want %v, got %v for %v", testCase.want, get, testCase.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package setting
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ConfigError is returned when a config file is not valid JSON or has an invalid setting.
+type ConfigError struct {
+	Path string
+	Line int
+
+	// Key is the key of the invalid setting, empty if the file is not valid JSON.
+	Key string
+
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	if e.Key == "" {
+		return e.Path + ":" + strconv.Itoa(e.Line) + ": " + e.Err.Error()
+	}
+
+	return e.Path + ":" + strconv.Itoa(e.Line) + ": " + e.Key + ": " + e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// configKeys are the keys of the settings in the config file with the functions updating the setting with their values.
+var configKeys = map[string]func(s *Setting, value json.RawMessage) error{
+	"nrOfConcurrentConnection": func(s *Setting, value json.RawMessage) error {
+		var n int
+		if err := json.Unmarshal(value, &n); err != nil {
+			return fmt.Errorf("%w: must be a number", ErrInvalidSetting)
+		}
+
+		return s.SetNrOfConcurrentConnection(n)
+	},
+	"speedLimit": func(s *Setting, value json.RawMessage) error {
+		var bytesPerSecond int64
+		if err := json.Unmarshal(value, &bytesPerSecond); err != nil {
+			return fmt.Errorf("%w: must be a number of bytes per second", ErrInvalidSetting)
+		}
+
+		return s.SetSpeedLimit(bytesPerSecond)
+	},
+	"globalSpeedLimit": func(s *Setting, value json.RawMessage) error {
+		var bytesPerSecond int64
+		if err := json.Unmarshal(value, &bytesPerSecond); err != nil {
+			return fmt.Errorf("%w: must be a number of bytes per second", ErrInvalidSetting)
+		}
+
+		return s.SetGlobalSpeedLimit(bytesPerSecond)
+	},
+	"userAgent": func(s *Setting, value json.RawMessage) error {
+		var userAgent string
+		if err := json.Unmarshal(value, &userAgent); err != nil {
+			return fmt.Errorf("%w: must be a string", ErrInvalidSetting)
+		}
+
+		return s.SetUserAgent(userAgent)
+	},
+	"saveDirectory": func(s *Setting, value json.RawMessage) error {
+		var saveDirectory string
+		if err := json.Unmarshal(value, &saveDirectory); err != nil {
+			return fmt.Errorf("%w: must be a string", ErrInvalidSetting)
+		}
+
+		return s.SetSaveDirectory(saveDirectory)
+	},
+	"connectTimeout": func(s *Setting, value json.RawMessage) error {
+		return setConfigDuration(value, s.SetConnectTimeout)
+	},
+	"tlsHandshakeTimeout": func(s *Setting, value json.RawMessage) error {
+		return setConfigDuration(value, s.SetTLSHandshakeTimeout)
+	},
+	"responseHeaderTimeout": func(s *Setting, value json.RawMessage) error {
+		return setConfigDuration(value, s.SetResponseHeaderTimeout)
+	},
+	"idleConnTimeout": func(s *Setting, value json.RawMessage) error {
+		return setConfigDuration(value, s.SetIdleConnTimeout)
+	},
+	"maxIdleConnsPerHost": func(s *Setting, value json.RawMessage) error {
+		var n int
+		if err := json.Unmarshal(value, &n); err != nil {
+			return fmt.Errorf("%w: must be a number", ErrInvalidSetting)
+		}
+
+		return s.SetMaxIdleConnsPerHost(n)
+	},
+	"keepAlive": func(s *Setting, value json.RawMessage) error {
+		var isKeepAliveEnabled bool
+		if err := json.Unmarshal(value, &isKeepAliveEnabled); err != nil {
+			return fmt.Errorf("%w: must be true or false", ErrInvalidSetting)
+		}
+
+		return s.SetKeepAlive(isKeepAliveEnabled)
+	},
+}
+
+// setConfigDuration updates a timeout with a duration in the config file, such as "30s" or "1m30s".
+func setConfigDuration(value json.RawMessage, set func(time.Duration) error) error {
+	var durationStr string
+	if err := json.Unmarshal(value, &durationStr); err != nil {
+		return fmt.Errorf(`%w: must be a duration such as "30s"`, ErrInvalidSetting)
+	}
+
+	duration, err := time.ParseDuration(durationStr)
+	if err != nil {
+		return fmt.Errorf(`%w: must be a duration such as "30s"`, ErrInvalidSetting)
+	}
+
+	return set(duration)
+}
+
+// DefaultConfigPath returns the path of the config file of the user, $XDG_CONFIG_HOME/qdm/config.
+// Without XDG_CONFIG_HOME set, it is in the config directory of the user given by os.UserConfigDir.
+func DefaultConfigPath() (string, error) {
+	configDirectory, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(configDirectory, "qdm", "config"), nil
+}
+
+// LoadSetting returns the setting saved in the JSON config file at the given path,
+// updated with the configurations from the parameter input.
+// The settings missing from the file, or all settings if the file does not exist, are defaulted.
+//
+// A config file that is not valid JSON or has an invalid setting returns a *ConfigError with the line of the error.
+// Unknown keys, such as the keys of settings added by a newer version, are ignored and kept when the setting is saved.
+func LoadSetting(path string, configurations ...ConfigOption) (*Setting, error) {
+	setting, err := NewSetting()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = setting.loadConfig(path, data)
+	} else if os.IsNotExist(err) {
+		err = nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, configuration := range configurations {
+		if err = configuration(setting); err != nil {
+			return nil, err
+		}
+	}
+
+	return setting, nil
+}
+
+// loadConfig updates the setting with the keys of the config file in order.
+func (s *Setting) loadConfig(path string, data []byte) error {
+	// The whole file is checked first, as the decoder does not give the offset of an error in a value
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+
+		offset := int64(0)
+		switch {
+		case errors.As(err, &syntaxErr):
+			offset = syntaxErr.Offset
+		case errors.As(err, &typeErr):
+			offset = typeErr.Offset
+			err = errors.New("config must be a JSON object")
+		}
+
+		return &ConfigError{Path: path, Line: lineOf(data, offset), Err: err}
+	}
+
+	if values == nil {
+		return &ConfigError{Path: path, Line: 1, Err: errors.New("config must be a JSON object")}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	// Skip the opening brace of the object
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		key := token.(string)
+		line := lineOf(data, decoder.InputOffset())
+
+		var value json.RawMessage
+		if err = decoder.Decode(&value); err != nil {
+			return err
+		}
+
+		set, ok := configKeys[key]
+		if !ok {
+			if s.unknownConfig == nil {
+				s.unknownConfig = make(map[string]json.RawMessage)
+			}
+
+			s.unknownConfig[key] = value
+
+			continue
+		}
+
+		if err = set(s, value); err != nil {
+			return &ConfigError{Path: path, Line: line, Key: key, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// lineOf returns the line number of the byte offset in the data.
+func lineOf(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// UnknownConfigKeys returns the keys of the config file that are not settings, in sorted order.
+func (s *Setting) UnknownConfigKeys() []string {
+	keys := make([]string, 0, len(s.unknownConfig))
+	for key := range s.unknownConfig {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// SaveConfig writes the setting to the JSON config file at the given path, creating its directory if needed,
+// and returns a non nil error if failed to save.
+//
+// The file is written to a temporary file in the same directory and renamed,
+// so the config file is either the previous or the new setting if the process is interrupted.
+func (s *Setting) SaveConfig(path string) error {
+	values := map[string]interface{}{
+		"nrOfConcurrentConnection": s.NrOfConcurrentConnection(),
+		"speedLimit":               s.SpeedLimit(),
+		"globalSpeedLimit":         s.GlobalSpeedLimit(),
+		"userAgent":                s.UserAgent(),
+		"saveDirectory":            s.SaveDirectory(),
+		"connectTimeout":           s.ConnectTimeout().String(),
+		"tlsHandshakeTimeout":      s.TLSHandshakeTimeout().String(),
+		"responseHeaderTimeout":    s.ResponseHeaderTimeout().String(),
+		"idleConnTimeout":          s.IdleConnTimeout().String(),
+		"maxIdleConnsPerHost":      s.MaxIdleConnsPerHost(),
+		"keepAlive":                s.IsKeepAliveEnabled(),
+	}
+
+	for key, value := range s.unknownConfig {
+		values[key] = value
+	}
+
+	data, err := json.MarshalIndent(values, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	directory := filepath.Dir(path)
+	if err = os.MkdirAll(directory, os.ModePerm); err != nil {
+		return err
+	}
+
+	tempFile, err := ioutil.TempFile(directory, filepath.Base(path)+".*.temp")
+	if err != nil {
+		return err
+	}
+
+	// The config file is readable by the user only, as the temporary file is created with that permission
+	_, err = tempFile.Write(append(data, '\n'))
+	if err == nil {
+		err = tempFile.Sync()
+	}
+
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tempFile.Name(), path)
+	}
+
+	// The temporary file is only left to remove if it was not renamed
+	if err != nil {
+		_ = os.Remove(tempFile.Name())
+		return fmt.Errorf("cannot save the config file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package setting_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/user/setting"
+)
+
+// newTestDirectory returns a temporary directory removed at the end of the test.
+func newTestDirectory(t *testing.T) string {
+	directory, err := ioutil.TempDir("", "setting")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = os.RemoveAll(directory)
+	})
+
+	return directory
+}
+
+func TestLoadSetting(t *testing.T) {
+	directory := newTestDirectory(t)
+
+	t.Run("Missing file", func(t *testing.T) {
+		s, err := setting.LoadSetting(filepath.Join(directory, "missing"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s.NrOfConcurrentConnection() != setting.DefaultNrOfConcurrentConnection {
+			t.Errorf("Want %v, got %v", setting.DefaultNrOfConcurrentConnection, s.NrOfConcurrentConnection())
+		}
+
+		if s.UserAgent() != setting.DefaultUserAgent {
+			t.Errorf("Want %v, got %v", setting.DefaultUserAgent, s.UserAgent())
+		}
+
+		if s.ConnectTimeout() != setting.DefaultConnectTimeout {
+			t.Errorf("Want %v, got %v", setting.DefaultConnectTimeout, s.ConnectTimeout())
+		}
+	})
+
+	t.Run("Config file", func(t *testing.T) {
+		path := filepath.Join(directory, "config")
+		data := `{
+	"nrOfConcurrentConnection": 4,
+	"speedLimit": 1024,
+	"userAgent": "Datasets/1.0",
+	"connectTimeout": "5s",
+	"keepAlive": false
+}
+`
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		// The configurations given override the config file
+		s, err := setting.LoadSetting(path, setting.SpeedLimit(2048))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var testCases = []struct {
+			name string
+			get  interface{}
+			want interface{}
+		}{
+			{name: "Connections", get: s.NrOfConcurrentConnection(), want: 4},
+			{name: "Speed limit", get: s.SpeedLimit(), want: int64(2048)},
+			{name: "User agent", get: s.UserAgent(), want: "Datasets/1.0"},
+			{name: "Connect timeout", get: s.ConnectTimeout(), want: 5 * time.Second},
+			{name: "Keep alive", get: s.IsKeepAliveEnabled(), want: false},
+			{name: "Missing setting", get: s.IdleConnTimeout(), want: setting.DefaultIdleConnTimeout},
+		}
+
+		for _, testCase := range testCases {
+			t.Run(testCase.name, func(t *testing.T) {
+				if !reflect.DeepEqual(testCase.get, testCase.want) {
+					t.Errorf("Want %v, got %v", testCase.want, testCase.get)
+				}
+			})
+		}
+	})
+}
+
+func TestLoadSettingError(t *testing.T) {
+	directory := newTestDirectory(t)
+	path := filepath.Join(directory, "config")
+
+	var testCases = []struct {
+		name               string
+		data               string
+		wantLine           int
+		wantKey            string
+		wantInvalidSetting bool
+	}{
+		{name: "Syntax error", data: "{\n\t\"userAgent\": \"Datasets\",\n\t\"speedLimit\": 1,,\n}\n", wantLine: 3},
+		{name: "Unterminated object", data: "{\n\t\"userAgent\": \"Datasets\"\n", wantLine: 3},
+		{name: "Not an object", data: "[]\n", wantLine: 1},
+		{name: "Invalid value", data: "{\n\t\"userAgent\": \"Datasets\",\n\t\"speedLimit\": -1\n}\n", wantLine: 3, wantKey: "speedLimit", wantInvalidSetting: true},
+		{name: "Invalid type", data: "{\n\n\t\"connectTimeout\": 30\n}\n", wantLine: 3, wantKey: "connectTimeout", wantInvalidSetting: true},
+		{name: "Invalid duration", data: "{\"idleConnTimeout\": \"soon\"}", wantLine: 1, wantKey: "idleConnTimeout", wantInvalidSetting: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := ioutil.WriteFile(path, []byte(testCase.data), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := setting.LoadSetting(path)
+
+			var configErr *setting.ConfigError
+			if !errors.As(err, &configErr) {
+				t.Fatalf("Want *ConfigError, got %v", err)
+			}
+
+			if configErr.Path != path || configErr.Line != testCase.wantLine || configErr.Key != testCase.wantKey {
+				t.Errorf("Want %s:%d key %q, got %s:%d key %q",
+					path, testCase.wantLine, testCase.wantKey, configErr.Path, configErr.Line, configErr.Key)
+			}
+
+			if errors.Is(err, setting.ErrInvalidSetting) != testCase.wantInvalidSetting {
+				t.Errorf("Want error wrapping ErrInvalidSetting %v, got %v", testCase.wantInvalidSetting, err)
+			}
+		})
+	}
+}
+
+func TestSaveConfig(t *testing.T) {
+	directory := newTestDirectory(t)
+	path := filepath.Join(directory, "qdm", "config")
+
+	// The config file and its directory are created
+	s, err := setting.NewSetting(setting.NrOfConcurrentConnection(4), setting.ConnectTimeout(5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.SaveConfig(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := setting.LoadSetting(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.String() != s.String() {
+		t.Errorf("Want %v, got %v", s, loaded)
+	}
+
+	// Unknown keys are kept when the setting is saved again
+	data := "{\n\t\"userAgent\": \"Datasets/1.0\",\n\t\"theme\": {\"color\": \"dark\"}\n}\n"
+	if err = ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err = setting.LoadSetting(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = loaded.SetSpeedLimit(1024); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = loaded.SaveConfig(path); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := setting.LoadSetting(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if keys := saved.UnknownConfigKeys(); !reflect.DeepEqual(keys, []string{"theme"}) {
+		t.Errorf("Want %v, got %v", []string{"theme"}, keys)
+	}
+
+	if saved.UserAgent() != "Datasets/1.0" || saved.SpeedLimit() != 1024 {
+		t.Errorf("Want user agent Datasets/1.0 and speed limit 1024, got %q and %d", saved.UserAgent(), saved.SpeedLimit())
+	}
+
+	// No temporary file is left next to the config file
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 || files[0].Name() != "config" {
+		t.Errorf("Want only the config file, got %d files", len(files))
+	}
+}
+
+func TestDefaultConfigPath(t *testing.T) {
+	xdgConfigHome, isSet := os.LookupEnv("XDG_CONFIG_HOME")
+	defer func() {
+		if isSet {
+			_ = os.Setenv("XDG_CONFIG_HOME", xdgConfigHome)
+		} else {
+			_ = os.Unsetenv("XDG_CONFIG_HOME")
+		}
+	}()
+
+	directory := newTestDirectory(t)
+	if err := os.Setenv("XDG_CONFIG_HOME", directory); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := setting.DefaultConfigPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// XDG_CONFIG_HOME is only used by os.UserConfigDir on Unix systems other than macOS
+	if runtime.GOOS != "windows" && runtime.GOOS != "darwin" && runtime.GOOS != "ios" && runtime.GOOS != "plan9" {
+		if want := filepath.Join(directory, "qdm", "config"); path != want {
+			t.Errorf("Want %v, got %v", want, path)
+		}
+	}
+}
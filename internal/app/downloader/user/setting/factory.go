@@ -1,11 +1,23 @@
 package setting
 
+import "time"
+
 // ConfigOption is the signature of functional option for Setting.
 type ConfigOption func(u *Setting) error
 
 // NewSetting returns a new instance of Setting.
 func NewSetting(configurations ...ConfigOption) (*Setting, error) {
-	setting := &Setting{}
+	setting := &Setting{
+		nrOfConcurrentConnection: DefaultNrOfConcurrentConnection,
+		userAgent:                DefaultUserAgent,
+		saveDirectory:            DefaultSaveDirectory,
+		connectTimeout:           DefaultConnectTimeout,
+		tlsHandshakeTimeout:      DefaultTLSHandshakeTimeout,
+		responseHeaderTimeout:    DefaultResponseHeaderTimeout,
+		idleConnTimeout:          DefaultIdleConnTimeout,
+		maxIdleConnsPerHost:      DefaultMaxIdleConnsPerHost,
+		isKeepAliveEnabled:       true,
+	}
 
 	for _, configuration := range configurations {
 		if err := configuration(setting); err != nil {
@@ -24,3 +36,73 @@ func NrOfConcurrentConnection(nrOfConcurrentConnection int) ConfigOption {
 		return s.SetNrOfConcurrentConnection(nrOfConcurrentConnection)
 	}
 }
+
+// SpeedLimit allows setting the maximum bytes per second used by each download.
+func SpeedLimit(bytesPerSecond int64) ConfigOption {
+	return func(s *Setting) error {
+		return s.SetSpeedLimit(bytesPerSecond)
+	}
+}
+
+// GlobalSpeedLimit allows setting the maximum bytes per second used by all downloads together.
+func GlobalSpeedLimit(bytesPerSecond int64) ConfigOption {
+	return func(s *Setting) error {
+		return s.SetGlobalSpeedLimit(bytesPerSecond)
+	}
+}
+
+// UserAgent allows setting the User-Agent header sent by downloads.
+func UserAgent(userAgent string) ConfigOption {
+	return func(s *Setting) error {
+		return s.SetUserAgent(userAgent)
+	}
+}
+
+// SaveDirectory allows setting the directory the downloads are saved in.
+func SaveDirectory(saveDirectory string) ConfigOption {
+	return func(s *Setting) error {
+		return s.SetSaveDirectory(saveDirectory)
+	}
+}
+
+// ConnectTimeout allows setting the maximum time to wait for a connection to the download server.
+func ConnectTimeout(connectTimeout time.Duration) ConfigOption {
+	return func(s *Setting) error {
+		return s.SetConnectTimeout(connectTimeout)
+	}
+}
+
+// TLSHandshakeTimeout allows setting the maximum time to wait for a TLS handshake.
+func TLSHandshakeTimeout(tlsHandshakeTimeout time.Duration) ConfigOption {
+	return func(s *Setting) error {
+		return s.SetTLSHandshakeTimeout(tlsHandshakeTimeout)
+	}
+}
+
+// ResponseHeaderTimeout allows setting the maximum time to wait for the response header after sending a request.
+func ResponseHeaderTimeout(responseHeaderTimeout time.Duration) ConfigOption {
+	return func(s *Setting) error {
+		return s.SetResponseHeaderTimeout(responseHeaderTimeout)
+	}
+}
+
+// IdleConnTimeout allows setting the maximum time an idle connection is kept open for reuse.
+func IdleConnTimeout(idleConnTimeout time.Duration) ConfigOption {
+	return func(s *Setting) error {
+		return s.SetIdleConnTimeout(idleConnTimeout)
+	}
+}
+
+// MaxIdleConnsPerHost allows setting the maximum number of idle connections kept open for reuse per host.
+func MaxIdleConnsPerHost(maxIdleConnsPerHost int) ConfigOption {
+	return func(s *Setting) error {
+		return s.SetMaxIdleConnsPerHost(maxIdleConnsPerHost)
+	}
+}
+
+// KeepAlive allows setting whether connections are kept open for reuse by later requests.
+func KeepAlive(isKeepAliveEnabled bool) ConfigOption {
+	return func(s *Setting) error {
+		return s.SetKeepAlive(isKeepAliveEnabled)
+	}
+}
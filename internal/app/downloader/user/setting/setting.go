@@ -2,17 +2,58 @@
 package setting
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
 	"strconv"
 	"strings"
-
-	"github.com/getlantern/errors"
+	"time"
 
 	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
 )
 
+// ErrInvalidSetting is returned when a setting is updated with an invalid value, such as a value out of its range.
+var ErrInvalidSetting = errors.New("invalid setting")
+
+// DefaultNrOfConcurrentConnection is the default number of concurrent connection of a download.
+const DefaultNrOfConcurrentConnection = 8
+
+// DefaultUserAgent is the User-Agent header sent by downloads without a user agent set.
+const DefaultUserAgent = "QuantumDownloadManager"
+
+// DefaultSaveDirectory is the directory the downloads are saved in without a save directory set,
+// the current working directory.
+const DefaultSaveDirectory = "."
+
+// Default transport settings.
+const (
+	DefaultConnectTimeout        = 30 * time.Second
+	DefaultTLSHandshakeTimeout   = 10 * time.Second
+	DefaultResponseHeaderTimeout = 30 * time.Second
+	DefaultIdleConnTimeout       = 90 * time.Second
+	DefaultMaxIdleConnsPerHost   = manager.MaxNrOfConcurrentConnectionAllowed
+)
+
 // Setting stores the settings of a user.
 type Setting struct {
 	nrOfConcurrentConnection int
+	speedLimit               int64
+	globalSpeedLimit         int64
+	userAgent                string
+	saveDirectory            string
+
+	// Transport settings
+	connectTimeout        time.Duration
+	tlsHandshakeTimeout   time.Duration
+	responseHeaderTimeout time.Duration
+	idleConnTimeout       time.Duration
+	maxIdleConnsPerHost   int
+	isKeepAliveEnabled    bool
+
+	// Keys of the config file that are not settings, kept when the setting is saved
+	unknownConfig map[string]json.RawMessage
 }
 
 // NrOfConcurrentConnection returns the number of concurrent connection set in user setting.
@@ -24,6 +65,7 @@ func (s *Setting) NrOfConcurrentConnection() int {
 //
 // If the number is over maximum limit, the maximum concurrent connection will be set and error will not be nil.
 // Similarly, if given number is less than 1, it will be defaulted to 1 and the return error will not be nil.
+// Both errors wrap ErrInvalidSetting.
 func (s *Setting) SetNrOfConcurrentConnection(nrOfConcurrentConnection int) error {
 	var err error
 
@@ -32,15 +74,15 @@ func (s *Setting) SetNrOfConcurrentConnection(nrOfConcurrentConnection int) erro
 	if nrOfConcurrentConnection > manager.MaxNrOfConcurrentConnectionAllowed {
 		// The given number exceeds the maximum allowed connection
 		// Defaulting to maximum concurrent connection
-		err = errors.New("defaulting to the maximum allowed concurrent connection (" +
-			strconv.Itoa(manager.MaxNrOfConcurrentConnectionAllowed) +
-			") as the given number exceeded maximum allowed")
+		err = fmt.Errorf("%w: defaulting to the maximum allowed concurrent connection (%d)"+
+			" as the given number exceeded maximum allowed",
+			ErrInvalidSetting, manager.MaxNrOfConcurrentConnectionAllowed)
 
 		s.nrOfConcurrentConnection = manager.MaxNrOfConcurrentConnectionAllowed
 	} else if nrOfConcurrentConnection < 1 {
 		// The given number is below 1
 		// Defaulting to 1
-		err = errors.New("defaulting to 1 concurrent connection as the given number is below 1")
+		err = fmt.Errorf("%w: defaulting to 1 concurrent connection as the given number is below 1", ErrInvalidSetting)
 
 		s.nrOfConcurrentConnection = 1
 	}
@@ -48,6 +90,199 @@ func (s *Setting) SetNrOfConcurrentConnection(nrOfConcurrentConnection int) erro
 	return err
 }
 
+// SpeedLimit returns the maximum bytes per second used by each download set in user setting, 0 if unlimited.
+func (s *Setting) SpeedLimit() int64 {
+	return s.speedLimit
+}
+
+// SetSpeedLimit updates the user setting with the maximum bytes per second used by each download.
+// A speed limit of 0 means unlimited and a negative speed limit returns an error wrapping ErrInvalidSetting.
+func (s *Setting) SetSpeedLimit(bytesPerSecond int64) error {
+	if bytesPerSecond < 0 {
+		return fmt.Errorf("%w: speed limit cannot be negative", ErrInvalidSetting)
+	}
+
+	s.speedLimit = bytesPerSecond
+
+	return nil
+}
+
+// GlobalSpeedLimit returns the maximum bytes per second used by all downloads together set in user setting,
+// 0 if unlimited.
+func (s *Setting) GlobalSpeedLimit() int64 {
+	return s.globalSpeedLimit
+}
+
+// SetGlobalSpeedLimit updates the user setting with the maximum bytes per second used by all downloads together.
+// A speed limit of 0 means unlimited and a negative speed limit returns an error wrapping ErrInvalidSetting.
+//
+// The global speed limit is applied to the downloads with manager.SetGlobalSpeedLimit.
+func (s *Setting) SetGlobalSpeedLimit(bytesPerSecond int64) error {
+	if bytesPerSecond < 0 {
+		return fmt.Errorf("%w: global speed limit cannot be negative", ErrInvalidSetting)
+	}
+
+	s.globalSpeedLimit = bytesPerSecond
+
+	return nil
+}
+
+// UserAgent returns the User-Agent header sent by downloads set in user setting.
+func (s *Setting) UserAgent() string {
+	return s.userAgent
+}
+
+// SetUserAgent updates the user setting with the User-Agent header sent by downloads.
+// It can be overridden per download with manager.UserAgent.
+// An empty user agent returns an error wrapping ErrInvalidSetting.
+func (s *Setting) SetUserAgent(userAgent string) error {
+	if userAgent == "" {
+		return fmt.Errorf("%w: user agent cannot be empty", ErrInvalidSetting)
+	}
+
+	s.userAgent = userAgent
+
+	return nil
+}
+
+// SaveDirectory returns the directory the downloads are saved in set in user setting.
+func (s *Setting) SaveDirectory() string {
+	return s.saveDirectory
+}
+
+// SetSaveDirectory updates the user setting with the directory the downloads are saved in.
+// It can be overridden per download with manager.SaveDirectory, which checks the directory exists.
+// An empty save directory returns an error wrapping ErrInvalidSetting.
+func (s *Setting) SetSaveDirectory(saveDirectory string) error {
+	if saveDirectory == "" {
+		return fmt.Errorf("%w: save directory cannot be empty", ErrInvalidSetting)
+	}
+
+	s.saveDirectory = saveDirectory
+
+	return nil
+}
+
+// ConnectTimeout returns the maximum time to wait for a connection to the download server, 0 if unlimited.
+func (s *Setting) ConnectTimeout() time.Duration {
+	return s.connectTimeout
+}
+
+// SetConnectTimeout updates the user setting with the maximum time to wait for a connection to the download server.
+// A timeout of 0 means unlimited and a negative timeout returns an error wrapping ErrInvalidSetting.
+func (s *Setting) SetConnectTimeout(connectTimeout time.Duration) error {
+	if connectTimeout < 0 {
+		return fmt.Errorf("%w: connect timeout cannot be negative", ErrInvalidSetting)
+	}
+
+	s.connectTimeout = connectTimeout
+
+	return nil
+}
+
+// TLSHandshakeTimeout returns the maximum time to wait for a TLS handshake, 0 if unlimited.
+func (s *Setting) TLSHandshakeTimeout() time.Duration {
+	return s.tlsHandshakeTimeout
+}
+
+// SetTLSHandshakeTimeout updates the user setting with the maximum time to wait for a TLS handshake.
+// A timeout of 0 means unlimited and a negative timeout returns an error wrapping ErrInvalidSetting.
+func (s *Setting) SetTLSHandshakeTimeout(tlsHandshakeTimeout time.Duration) error {
+	if tlsHandshakeTimeout < 0 {
+		return fmt.Errorf("%w: TLS handshake timeout cannot be negative", ErrInvalidSetting)
+	}
+
+	s.tlsHandshakeTimeout = tlsHandshakeTimeout
+
+	return nil
+}
+
+// ResponseHeaderTimeout returns the maximum time to wait for the response header after sending a request,
+// 0 if unlimited.
+func (s *Setting) ResponseHeaderTimeout() time.Duration {
+	return s.responseHeaderTimeout
+}
+
+// SetResponseHeaderTimeout updates the user setting with the maximum time to wait for the response header
+// after sending a request.
+// A timeout of 0 means unlimited and a negative timeout returns an error wrapping ErrInvalidSetting.
+func (s *Setting) SetResponseHeaderTimeout(responseHeaderTimeout time.Duration) error {
+	if responseHeaderTimeout < 0 {
+		return fmt.Errorf("%w: response header timeout cannot be negative", ErrInvalidSetting)
+	}
+
+	s.responseHeaderTimeout = responseHeaderTimeout
+
+	return nil
+}
+
+// IdleConnTimeout returns the maximum time an idle connection is kept open for reuse, 0 if unlimited.
+func (s *Setting) IdleConnTimeout() time.Duration {
+	return s.idleConnTimeout
+}
+
+// SetIdleConnTimeout updates the user setting with the maximum time an idle connection is kept open for reuse.
+// A timeout of 0 means unlimited and a negative timeout returns an error wrapping ErrInvalidSetting.
+func (s *Setting) SetIdleConnTimeout(idleConnTimeout time.Duration) error {
+	if idleConnTimeout < 0 {
+		return fmt.Errorf("%w: idle connection timeout cannot be negative", ErrInvalidSetting)
+	}
+
+	s.idleConnTimeout = idleConnTimeout
+
+	return nil
+}
+
+// MaxIdleConnsPerHost returns the maximum number of idle connections kept open for reuse per host.
+func (s *Setting) MaxIdleConnsPerHost() int {
+	return s.maxIdleConnsPerHost
+}
+
+// SetMaxIdleConnsPerHost updates the user setting with the maximum number of idle connections
+// kept open for reuse per host.
+// A negative number returns an error wrapping ErrInvalidSetting.
+func (s *Setting) SetMaxIdleConnsPerHost(maxIdleConnsPerHost int) error {
+	if maxIdleConnsPerHost < 0 {
+		return fmt.Errorf("%w: maximum idle connections per host cannot be negative", ErrInvalidSetting)
+	}
+
+	s.maxIdleConnsPerHost = maxIdleConnsPerHost
+
+	return nil
+}
+
+// IsKeepAliveEnabled returns a boolean indicating whether connections are kept open for reuse by later requests.
+func (s *Setting) IsKeepAliveEnabled() bool {
+	return s.isKeepAliveEnabled
+}
+
+// SetKeepAlive updates the user setting with whether connections are kept open for reuse by later requests.
+func (s *Setting) SetKeepAlive(isKeepAliveEnabled bool) error {
+	s.isKeepAliveEnabled = isKeepAliveEnabled
+
+	return nil
+}
+
+// Transport returns a new HTTP transport for the downloads configured with the transport settings,
+// to be used with manager.Transport.
+// Proxies are taken from the environment as with http.DefaultTransport.
+func (s *Setting) Transport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   s.ConnectTimeout(),
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = s.TLSHandshakeTimeout()
+	transport.ResponseHeaderTimeout = s.ResponseHeaderTimeout()
+	transport.IdleConnTimeout = s.IdleConnTimeout()
+	transport.MaxIdleConnsPerHost = s.MaxIdleConnsPerHost()
+	transport.DisableKeepAlives = !s.IsKeepAliveEnabled()
+
+	return transport
+}
+
 func (s *Setting) String() string {
 	sb := strings.Builder{}
 
@@ -55,5 +290,45 @@ func (s *Setting) String() string {
 	sb.WriteString(strconv.Itoa(s.NrOfConcurrentConnection()))
 	sb.WriteString("\n")
 
+	sb.WriteString("Speed limit: ")
+	sb.WriteString(strconv.FormatInt(s.SpeedLimit(), 10))
+	sb.WriteString("\n")
+
+	sb.WriteString("Global speed limit: ")
+	sb.WriteString(strconv.FormatInt(s.GlobalSpeedLimit(), 10))
+	sb.WriteString("\n")
+
+	sb.WriteString("User agent: ")
+	sb.WriteString(s.UserAgent())
+	sb.WriteString("\n")
+
+	sb.WriteString("Save directory: ")
+	sb.WriteString(s.SaveDirectory())
+	sb.WriteString("\n")
+
+	sb.WriteString("Connect timeout: ")
+	sb.WriteString(s.ConnectTimeout().String())
+	sb.WriteString("\n")
+
+	sb.WriteString("TLS handshake timeout: ")
+	sb.WriteString(s.TLSHandshakeTimeout().String())
+	sb.WriteString("\n")
+
+	sb.WriteString("Response header timeout: ")
+	sb.WriteString(s.ResponseHeaderTimeout().String())
+	sb.WriteString("\n")
+
+	sb.WriteString("Idle connection timeout: ")
+	sb.WriteString(s.IdleConnTimeout().String())
+	sb.WriteString("\n")
+
+	sb.WriteString("Maximum idle connections per host: ")
+	sb.WriteString(strconv.Itoa(s.MaxIdleConnsPerHost()))
+	sb.WriteString("\n")
+
+	sb.WriteString("Keep alive: ")
+	sb.WriteString(strconv.FormatBool(s.IsKeepAliveEnabled()))
+	sb.WriteString("\n")
+
 	return sb.String()
 }
@@ -0,0 +1,189 @@
+package setting_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/manager"
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/user/setting"
+)
+
+func TestTransport(t *testing.T) {
+	var testCases = []struct {
+		name             string
+		configurations   []setting.ConfigOption
+		wantTLSHandshake time.Duration
+		wantHeader       time.Duration
+		wantIdleConn     time.Duration
+		wantMaxIdleConns int
+		wantNoKeepAlive  bool
+	}{
+		{
+			name:             "Default",
+			wantTLSHandshake: setting.DefaultTLSHandshakeTimeout,
+			wantHeader:       setting.DefaultResponseHeaderTimeout,
+			wantIdleConn:     setting.DefaultIdleConnTimeout,
+			wantMaxIdleConns: setting.DefaultMaxIdleConnsPerHost,
+		},
+		{
+			name: "Configured",
+			configurations: []setting.ConfigOption{
+				setting.ConnectTimeout(time.Second),
+				setting.TLSHandshakeTimeout(2 * time.Second),
+				setting.ResponseHeaderTimeout(3 * time.Second),
+				setting.IdleConnTimeout(4 * time.Second),
+				setting.MaxIdleConnsPerHost(5),
+				setting.KeepAlive(false),
+			},
+			wantTLSHandshake: 2 * time.Second,
+			wantHeader:       3 * time.Second,
+			wantIdleConn:     4 * time.Second,
+			wantMaxIdleConns: 5,
+			wantNoKeepAlive:  true,
+		},
+		{
+			name: "Unlimited",
+			configurations: []setting.ConfigOption{
+				setting.ConnectTimeout(0),
+				setting.TLSHandshakeTimeout(0),
+				setting.ResponseHeaderTimeout(0),
+				setting.IdleConnTimeout(0),
+				setting.MaxIdleConnsPerHost(0),
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, err := setting.NewSetting(testCase.configurations...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			transport := s.Transport()
+
+			if transport.TLSHandshakeTimeout != testCase.wantTLSHandshake {
+				t.Errorf("Want TLS handshake timeout %v, got %v", testCase.wantTLSHandshake, transport.TLSHandshakeTimeout)
+			}
+
+			if transport.ResponseHeaderTimeout != testCase.wantHeader {
+				t.Errorf("Want response header timeout %v, got %v", testCase.wantHeader, transport.ResponseHeaderTimeout)
+			}
+
+			if transport.IdleConnTimeout != testCase.wantIdleConn {
+				t.Errorf("Want idle connection timeout %v, got %v", testCase.wantIdleConn, transport.IdleConnTimeout)
+			}
+
+			if transport.MaxIdleConnsPerHost != testCase.wantMaxIdleConns {
+				t.Errorf("Want maximum idle connections per host %v, got %v", testCase.wantMaxIdleConns, transport.MaxIdleConnsPerHost)
+			}
+
+			if transport.DisableKeepAlives != testCase.wantNoKeepAlive {
+				t.Errorf("Want keep alive disabled %v, got %v", testCase.wantNoKeepAlive, transport.DisableKeepAlives)
+			}
+
+			// The connect timeout is applied by the dialer, and proxies are taken from the environment
+			if transport.DialContext == nil {
+				t.Error("Want dialer with the connect timeout, got nil")
+			}
+
+			if transport.Proxy == nil {
+				t.Error("Want proxy from the environment, got nil")
+			}
+		})
+	}
+}
+
+func TestSetterErrors(t *testing.T) {
+	s, err := setting.NewSetting()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		name string
+		set  func() error
+		get  func() interface{}
+		want interface{}
+	}{
+		{
+			name: "Too many connections",
+			set:  func() error { return s.SetNrOfConcurrentConnection(1000) },
+			get:  func() interface{} { return s.NrOfConcurrentConnection() },
+			want: manager.MaxNrOfConcurrentConnectionAllowed,
+		},
+		{
+			name: "No connection",
+			set:  func() error { return s.SetNrOfConcurrentConnection(0) },
+			get:  func() interface{} { return s.NrOfConcurrentConnection() },
+			want: 1,
+		},
+		{
+			name: "Negative speed limit",
+			set:  func() error { return s.SetSpeedLimit(-1) },
+			get:  func() interface{} { return s.SpeedLimit() },
+			want: int64(0),
+		},
+		{
+			name: "Negative global speed limit",
+			set:  func() error { return s.SetGlobalSpeedLimit(-1) },
+			get:  func() interface{} { return s.GlobalSpeedLimit() },
+			want: int64(0),
+		},
+		{
+			name: "Empty user agent",
+			set:  func() error { return s.SetUserAgent("") },
+			get:  func() interface{} { return s.UserAgent() },
+			want: setting.DefaultUserAgent,
+		},
+		{
+			name: "Empty save directory",
+			set:  func() error { return s.SetSaveDirectory("") },
+			get:  func() interface{} { return s.SaveDirectory() },
+			want: setting.DefaultSaveDirectory,
+		},
+		{
+			name: "Negative connect timeout",
+			set:  func() error { return s.SetConnectTimeout(-time.Second) },
+			get:  func() interface{} { return s.ConnectTimeout() },
+			want: setting.DefaultConnectTimeout,
+		},
+		{
+			name: "Negative TLS handshake timeout",
+			set:  func() error { return s.SetTLSHandshakeTimeout(-time.Second) },
+			get:  func() interface{} { return s.TLSHandshakeTimeout() },
+			want: setting.DefaultTLSHandshakeTimeout,
+		},
+		{
+			name: "Negative response header timeout",
+			set:  func() error { return s.SetResponseHeaderTimeout(-time.Second) },
+			get:  func() interface{} { return s.ResponseHeaderTimeout() },
+			want: setting.DefaultResponseHeaderTimeout,
+		},
+		{
+			name: "Negative idle connection timeout",
+			set:  func() error { return s.SetIdleConnTimeout(-time.Second) },
+			get:  func() interface{} { return s.IdleConnTimeout() },
+			want: setting.DefaultIdleConnTimeout,
+		},
+		{
+			name: "Negative maximum idle connections per host",
+			set:  func() error { return s.SetMaxIdleConnsPerHost(-1) },
+			get:  func() interface{} { return s.MaxIdleConnsPerHost() },
+			want: setting.DefaultMaxIdleConnsPerHost,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := testCase.set(); !errors.Is(err, setting.ErrInvalidSetting) {
+				t.Errorf("Want error %v, got %v", setting.ErrInvalidSetting, err)
+			}
+
+			if get := testCase.get(); get != testCase.want {
+				t.Errorf("Want %v, got %v", testCase.want, get)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// Package bandwidth contains utilities that limit the bandwidth used by downloads.
+package bandwidth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxWait is the longest a waiter sleeps before checking the limit again,
+// so a limit changed while downloading applies quickly.
+const maxWait = 100 * time.Millisecond
+
+// Limiter is a token bucket limiting the number of bytes per second
+// shared by all readers waiting on it.
+// The bucket holds up to one second worth of bytes.
+type Limiter struct {
+	mu             sync.Mutex
+	bytesPerSecond int64
+	tokens         float64
+	lastRefill     time.Time
+}
+
+// NewLimiter returns a new Limiter with the given limit in bytes per second.
+// A limit of 0 means unlimited.
+func NewLimiter(bytesPerSecond int64) *Limiter {
+	l := &Limiter{}
+	l.SetLimit(bytesPerSecond)
+
+	return l
+}
+
+// Limit returns the limit in bytes per second, 0 if unlimited.
+func (l *Limiter) Limit() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.bytesPerSecond
+}
+
+// SetLimit updates the limit in bytes per second, which also applies to current waiters.
+// A limit of 0 or less means unlimited.
+func (l *Limiter) SetLimit(bytesPerSecond int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if bytesPerSecond < 0 {
+		bytesPerSecond = 0
+	}
+
+	// Refill at the previous limit
+	l.refill(time.Now())
+
+	// Going from unlimited to limited starts with a full bucket
+	if l.bytesPerSecond == 0 {
+		l.tokens = float64(bytesPerSecond)
+	}
+
+	l.bytesPerSecond = bytesPerSecond
+
+	if l.tokens > float64(bytesPerSecond) {
+		l.tokens = float64(bytesPerSecond)
+	}
+}
+
+// WaitN takes n bytes from the bucket and blocks until the bucket is no longer in debt,
+// or the context is done.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	l.mu.Lock()
+
+	l.refill(time.Now())
+	if l.bytesPerSecond > 0 {
+		l.tokens -= float64(n)
+	}
+
+	for l.bytesPerSecond > 0 && l.tokens < 0 {
+		wait := time.Duration(-l.tokens / float64(l.bytesPerSecond) * float64(time.Second))
+		if wait > maxWait {
+			wait = maxWait
+		}
+
+		l.mu.Unlock()
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		l.mu.Lock()
+		l.refill(time.Now())
+	}
+
+	l.mu.Unlock()
+
+	return nil
+}
+
+// refill adds the bytes allowed since the last refill to the bucket.
+// The caller must hold the lock.
+func (l *Limiter) refill(now time.Time) {
+	if l.bytesPerSecond <= 0 {
+		l.tokens = 0
+		l.lastRefill = now
+
+		return
+	}
+
+	l.tokens += now.Sub(l.lastRefill).Seconds() * float64(l.bytesPerSecond)
+	if l.tokens > float64(l.bytesPerSecond) {
+		l.tokens = float64(l.bytesPerSecond)
+	}
+
+	l.lastRefill = now
+}
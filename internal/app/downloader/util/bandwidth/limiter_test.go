@@ -0,0 +1,77 @@
+package bandwidth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/util/bandwidth"
+)
+
+func TestLimiterWaitN(t *testing.T) {
+	var testCases = []struct {
+		name           string
+		bytesPerSecond int64
+		bytes          int
+		minDuration    time.Duration
+		maxDuration    time.Duration
+	}{
+		{name: "Unlimited", bytesPerSecond: 0, bytes: 1024 * 1024, maxDuration: 100 * time.Millisecond},
+		{name: "Within burst", bytesPerSecond: 1024 * 1024, bytes: 512 * 1024, maxDuration: 100 * time.Millisecond},
+		{name: "Limited", bytesPerSecond: 256 * 1024, bytes: 512 * 1024, minDuration: 900 * time.Millisecond, maxDuration: 3 * time.Second},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			l := bandwidth.NewLimiter(testCase.bytesPerSecond)
+			start := time.Now()
+
+			for i := 0; i < testCase.bytes; i += 32 * 1024 {
+				if err := l.WaitN(context.Background(), 32*1024); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if get := time.Since(start); get < testCase.minDuration || get > testCase.maxDuration {
+				t.Errorf("Want duration between %s and %s, got %s", testCase.minDuration, testCase.maxDuration, get)
+			}
+		})
+	}
+}
+
+func TestLimiterSetLimit(t *testing.T) {
+	l := bandwidth.NewLimiter(1024)
+
+	// Put the bucket far into debt
+	done := make(chan error)
+	go func() {
+		done <- l.WaitN(context.Background(), 1024*1024)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	l.SetLimit(0)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Want waiter released after removing the limit")
+	}
+
+	if l.Limit() != 0 {
+		t.Errorf("Want limit 0, got %d", l.Limit())
+	}
+}
+
+func TestLimiterContextDone(t *testing.T) {
+	l := bandwidth.NewLimiter(1024)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := l.WaitN(ctx, 1024*1024); err != context.DeadlineExceeded {
+		t.Errorf("Want %v, got %v", context.DeadlineExceeded, err)
+	}
+}
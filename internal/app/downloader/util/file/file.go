@@ -2,6 +2,8 @@
 package file
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -32,3 +34,25 @@ func IsCleanedFileExist(path string) bool {
 
 	return IsFileExist(path)
 }
+
+// ErrDirectoryNotFound is returned when a directory does not exist or the path is not a directory.
+var ErrDirectoryNotFound = errors.New("directory not found")
+
+// CheckDirectory cleans the path and returns a non nil error wrapping ErrDirectoryNotFound
+// if it is not an existing directory.
+func CheckDirectory(path string) error {
+	fileInfo, err := os.Stat(CleanPath(path))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", ErrDirectoryNotFound, path)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !fileInfo.IsDir() {
+		return fmt.Errorf("%w: %s is not a directory", ErrDirectoryNotFound, path)
+	}
+
+	return nil
+}
@@ -1,6 +1,9 @@
 package file_test
 
 import (
+	"errors"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -29,3 +32,34 @@ func TestIsFileExist(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckDirectory(t *testing.T) {
+	directory, err := ioutil.TempDir("", "qdm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	filePath := filepath.Join(directory, "download.bin")
+	if err = ioutil.WriteFile(filePath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		name    string
+		path    string
+		wantErr error
+	}{
+		{name: "Directory", path: directory + " "},
+		{name: "Missing directory", path: filepath.Join(directory, "missing"), wantErr: file.ErrDirectoryNotFound},
+		{name: "File", path: filePath, wantErr: file.ErrDirectoryNotFound},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := file.CheckDirectory(testCase.path); !errors.Is(err, testCase.wantErr) {
+				t.Errorf("Want error %v, got %v", testCase.wantErr, err)
+			}
+		})
+	}
+}
@@ -1,8 +1,49 @@
 package file
 
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
 // Size represents file size in bytes
 type Size int64
 
+// sizeUnits are the multipliers of the units accepted by ParseSize, longest suffix first
+var sizeUnits = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30}, {"TIB", 1 << 40},
+	{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"TB", 1 << 40},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40},
+	{"B", 1},
+}
+
+// ParseSize parses a size in bytes or with a binary unit, such as "1024", "500K", "1.5MB" or "2GiB".
+// Units are case insensitive and a kilobyte is 1024 bytes, as with Size.KB.
+func ParseSize(s string) (Size, error) {
+	number := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := 1.0
+
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(number, unit.suffix) {
+			number = strings.TrimSpace(strings.TrimSuffix(number, unit.suffix))
+			multiplier = unit.multiplier
+
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 || math.IsInf(value, 0) || math.IsNaN(value) {
+		return 0, errors.New("invalid size: " + s)
+	}
+
+	return Size(value * multiplier), nil
+}
+
 // Bytes returns the file size in bytes
 func (s Size) Bytes() int64 {
 	return int64(s)
@@ -0,0 +1,40 @@
+package file_test
+
+import (
+	"testing"
+
+	"github.com/ttimt/QuantumDownloadManager/internal/app/downloader/util/file"
+)
+
+func TestParseSize(t *testing.T) {
+	var testCases = []struct {
+		size    string
+		want    file.Size
+		wantErr bool
+	}{
+		{size: "1024", want: 1024},
+		{size: "0", want: 0},
+		{size: "500K", want: 500 * 1024},
+		{size: "1.5MB", want: 1536 * 1024},
+		{size: "2 GiB", want: 2 << 30},
+		{size: "1t", want: 1 << 40},
+		{size: "100B", want: 100},
+		{size: "", wantErr: true},
+		{size: "-1K", wantErr: true},
+		{size: "10X", wantErr: true},
+		{size: "Inf", wantErr: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.size, func(t *testing.T) {
+			get, err := file.ParseSize(testCase.size)
+			if (err != nil) != testCase.wantErr {
+				t.Fatalf("Want error %v, got %v", testCase.wantErr, err)
+			}
+
+			if get != testCase.want {
+				t.Errorf("Want %d, got %d", testCase.want, get)
+			}
+		})
+	}
+}